@@ -1,54 +1,204 @@
 package aladhan
 
 import (
+	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
+	"io/ioutil"
 	"log"
 	"net/http"
+	"net/url"
+	"strconv"
 	"strings"
 	"time"
 )
 
-// GetMonthCalendar calls adhan API and returns serialized `MonthlyAdhanCalenderResponse` object from JSON response
-// API Endpoint: https://aladhan.com/prayer-times-api#GetCalendarByCitys
-// API Adhan Timing Tuning: https://aladhan.com/calculation-methods
+// Params holds the calculation parameters of the Adhan API
+// API Calculation Methods: https://aladhan.com/calculation-methods
+type Params struct {
+	Method                   int    // calculation method id; 99 for custom `MethodSettings`
+	MethodSettings           string // custom method "fajrAngle,maghribAngle,ishaAngle"; i.e. "18.5,null,90 min"
+	School                   int    // 0 for Shafi (standard), 1 for Hanafi
+	LatitudeAdjustmentMethod int    // 1 for middle of the night, 2 for one seventh, 3 for angle based; 0 for API default
+	MidnightMode             int    // 0 for standard (mid sunset to sunrise), 1 for jafari (mid sunset to fajr)
+	HijriAdjustment          int    // number of days to adjust the hijri date by; i.e. -1 or 1 for local moon sighting
+}
+
+// CalendarRequest identifies the monthly calendar to retrieve.
+// The location is resolved from the coordinates, the address, or the city and country (in order of precedence).
+type CalendarRequest struct {
+	City      string
+	Country   string
+	Address   string
+	Latitude  float64
+	Longitude float64
+	Offsets   string // comma separated adhan offsets (in mins) for the 5 daily adhans
+	Month     time.Month
+	Year      int
+	Params    Params
+}
+
+// hasCoordinates reports whether the request location is set by latitude and longitude
+func (req CalendarRequest) hasCoordinates() bool {
+	return req.Latitude != 0 || req.Longitude != 0
+}
+
+// calendarURL returns the API URL of the calendar endpoint for the request location
+// API Endpoints: https://aladhan.com/prayer-times-api#GetCalendar
 // Example request: `curl 'http://api.aladhan.com/v1/calendarByCity?city=Auckland&country=NewZealand&method=3&month=12&year=2020&tune=0,0,0,0,0,0,0,0'`
-func GetMonthCalendar(city string, country string, offsets string, month time.Month, year int) MonthlyAdhanCalenderResponse {
-	offsetSlice := strings.Split(offsets, ",")
+func (c *Client) calendarURL(req CalendarRequest) (string, error) {
+	offsetSlice, err := ParseOffsets(req.Offsets)
+	if err != nil {
+		return "", err
+	}
 	fajr, dhuhr, asr, maghrib, isha := offsetSlice[0], offsetSlice[1], offsetSlice[2], offsetSlice[3], offsetSlice[4]
+
+	query := url.Values{}
+	var endpoint string
+	switch {
+	case req.hasCoordinates():
+		endpoint = "calendar"
+		query.Set("latitude", strconv.FormatFloat(req.Latitude, 'f', -1, 64))
+		query.Set("longitude", strconv.FormatFloat(req.Longitude, 'f', -1, 64))
+	case req.Address != "":
+		endpoint = "calendarByAddress"
+		query.Set("address", req.Address)
+	default:
+		endpoint = "calendarByCity"
+		query.Set("city", req.City)
+		query.Set("country", req.Country)
+	}
+
+	query.Set("method", strconv.Itoa(req.Params.Method))
+	query.Set("school", strconv.Itoa(req.Params.School))
+	query.Set("midnightMode", strconv.Itoa(req.Params.MidnightMode))
+	if req.Params.LatitudeAdjustmentMethod != 0 {
+		query.Set("latitudeAdjustmentMethod", strconv.Itoa(req.Params.LatitudeAdjustmentMethod))
+	}
+	if req.Params.MethodSettings != "" {
+		query.Set("methodSettings", req.Params.MethodSettings)
+	}
+	if req.Params.HijriAdjustment != 0 {
+		query.Set("adjustment", strconv.Itoa(req.Params.HijriAdjustment))
+	}
+	query.Set("month", strconv.Itoa(int(req.Month)))
+	query.Set("year", strconv.Itoa(req.Year))
 	// Tune order: Imsak,Fajr,Sunrise,Dhuhr,Asr,Maghrib,Sunset,Isha,Midnight
-	tuneListStr := fmt.Sprintf("0,%s,0,%s,%s,%s,0,%s", fajr, dhuhr, asr, maghrib, isha)
-	url := fmt.Sprintf(
-		"http://api.aladhan.com/v1/calendarByCity?city=%s&country=%s&method=3&month=%d&year=%d&tune=%s",
-		city,
-		country,
-		month,
-		year,
-		tuneListStr,
-	)
+	query.Set("tune", fmt.Sprintf("0,%d,0,%d,%d,%d,0,%d", fajr, dhuhr, asr, maghrib, isha))
+
+	return fmt.Sprintf("%s/%s?%s", strings.TrimSuffix(c.BaseURL, "/"), endpoint, query.Encode()), nil
+}
+
+// GetMonthCalendar calls adhan API using the `DefaultClient` and returns serialized `MonthlyAdhanCalenderResponse` object from JSON response
+func GetMonthCalendar(req CalendarRequest) (MonthlyAdhanCalenderResponse, error) {
+	return DefaultClient.GetMonthCalendar(context.Background(), req)
+}
+
+// GetMonthCalendar calls adhan API and returns serialized `MonthlyAdhanCalenderResponse` object from JSON response.
+// Failed requests are retried with exponential backoff according to the client retry policy, until the context is done.
+// API Adhan Timing Tuning: https://aladhan.com/calculation-methods
+func (c *Client) GetMonthCalendar(ctx context.Context, req CalendarRequest) (MonthlyAdhanCalenderResponse, error) {
+	url, err := c.calendarURL(req)
+	if err != nil {
+		return MonthlyAdhanCalenderResponse{}, err
+	}
+
+	for attempt := 0; ; attempt++ {
+		monthlyCalendarResp, err := c.getMonthCalendar(ctx, url)
+		if err == nil || !isRetryable(err) || attempt >= c.Retry.MaxRetries {
+			return monthlyCalendarResp, err
+		}
+
+		backoff := c.Retry.backoff(attempt)
+		log.Printf("API request failed, retrying in %s (attempt %d of %d): %s", backoff, attempt+1, c.Retry.MaxRetries, err)
+		select {
+		case <-ctx.Done():
+			return MonthlyAdhanCalenderResponse{}, &RequestError{URL: url, Err: ctx.Err()}
+		case <-time.After(backoff):
+		}
+	}
+}
+
+// getMonthCalendar performs a single calendar API request
+func (c *Client) getMonthCalendar(ctx context.Context, url string) (MonthlyAdhanCalenderResponse, error) {
+	httpReq, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return MonthlyAdhanCalenderResponse{}, &RequestError{URL: url, Err: err}
+	}
 
 	log.Printf("Calling API: %s", url)
-	resp, err := http.Get(url)
+	resp, err := c.HTTPClient.Do(httpReq)
 	if err != nil {
-		log.Fatalf("API request to URL %s failed", url)
+		return MonthlyAdhanCalenderResponse{}, &RequestError{URL: url, Err: err}
 	}
 	defer resp.Body.Close()
 
+	body, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		return MonthlyAdhanCalenderResponse{}, &RequestError{URL: url, Err: err}
+	}
+
+	// Error responses carry a message in place of the calendar data
+	var envelope struct {
+		Code   int64           `json:"code"`
+		Status string          `json:"status"`
+		Data   json.RawMessage `json:"data"`
+	}
+	if err := json.Unmarshal(body, &envelope); err != nil {
+		if resp.StatusCode != http.StatusOK {
+			return MonthlyAdhanCalenderResponse{}, &StatusError{Code: int64(resp.StatusCode), Status: resp.Status}
+		}
+		return MonthlyAdhanCalenderResponse{}, &DecodeError{Err: err}
+	}
+	if resp.StatusCode == http.StatusOK && envelope.Code == 0 {
+		return MonthlyAdhanCalenderResponse{}, &DecodeError{Err: errors.New("malformed response; missing response code")}
+	}
+	if resp.StatusCode != http.StatusOK || envelope.Code != http.StatusOK {
+		statusErr := &StatusError{Code: envelope.Code, Status: envelope.Status}
+		if statusErr.Code == 0 || statusErr.Code == http.StatusOK {
+			statusErr.Code, statusErr.Status = int64(resp.StatusCode), resp.Status
+		}
+		var msg string
+		if json.Unmarshal(envelope.Data, &msg) == nil && msg != "" {
+			statusErr.Status = fmt.Sprintf("%s; %s", statusErr.Status, msg)
+		}
+		return MonthlyAdhanCalenderResponse{}, statusErr
+	}
+
 	var monthlyCalendarResp MonthlyAdhanCalenderResponse
-	if err := json.NewDecoder(resp.Body).Decode(&monthlyCalendarResp); err != nil {
-		log.Fatalf("Failed to decode URL response, incorrect struct formatting and/or field type(s)")
+	if err := json.Unmarshal(body, &monthlyCalendarResp); err != nil {
+		return MonthlyAdhanCalenderResponse{}, &DecodeError{Err: err}
 	}
 
-	// Remove non-main adhans
+	// Remove timings other than the adhans, imsak, sunrise, sunset and midnight
 	for _, timings := range monthlyCalendarResp.Data {
 		for adhan := range timings.Timings {
 			switch adhan {
-			case Fajr, Dhuhr, Asr, Maghrib, Isha:
+			case Fajr, Dhuhr, Asr, Maghrib, Isha, Imsak, Sunrise, Sunset, Midnight:
 			default:
 				delete(timings.Timings, adhan)
 			}
 		}
 	}
 
-	return monthlyCalendarResp
+	return monthlyCalendarResp, nil
+}
+
+// ParseOffsets parses the comma separated adhan offsets (in mins) for the 5 daily adhans
+func ParseOffsets(offsets string) ([]int, error) {
+	offsetSlice := strings.Split(offsets, ",")
+	if len(offsetSlice) != 5 {
+		return nil, &OffsetError{Offsets: offsets}
+	}
+
+	parsed := make([]int, len(offsetSlice))
+	for i, offset := range offsetSlice {
+		value, err := strconv.Atoi(strings.TrimSpace(offset))
+		if err != nil {
+			return nil, &OffsetError{Offsets: offsets}
+		}
+		parsed[i] = value
+	}
+	return parsed, nil
 }
@@ -1,12 +1,39 @@
 package aladhan
 
-import "testing"
+import (
+	"context"
+	"errors"
+	"flag"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"path/filepath"
+	"testing"
+	"time"
+)
 
-// TestGetMonthCalendar is an API integration test
+var live = flag.Bool("live", false, "run integration tests against the live Adhan API")
+
+// TestGetMonthCalendar is an API integration test; run with `go test ./aladhan -live`
 func TestGetMonthCalendar(t *testing.T) {
+	if !*live {
+		t.Skip("skipping live API integration test; enable with -live flag")
+	}
+
 	t.Run("gets calendar for Auckland NewZealand - successful API status with timezone", func(t *testing.T) {
 
-		got := GetMonthCalendar("Auckland", "NewZealand", "0,0,0,0,0", 1, 1)
+		got, err := GetMonthCalendar(CalendarRequest{
+			City:    "Auckland",
+			Country: "NewZealand",
+			Offsets: "0,0,0,0,0",
+			Month:   1,
+			Year:    1,
+			Params:  Params{Method: 3},
+		})
+		if err != nil {
+			t.Fatalf("unexpected error: %s", err)
+		}
 
 		want := MonthlyAdhanCalenderResponse{Code: 200, Status: "OK"}
 
@@ -25,3 +52,216 @@ func TestGetMonthCalendar(t *testing.T) {
 		}
 	})
 }
+
+// fixtureServer returns a test server responding with the status code and recorded API response from testdata;
+// the query of the last request is sent to the returned channel
+func fixtureServer(t *testing.T, status int, fixture string) (*httptest.Server, <-chan url.Values) {
+	t.Helper()
+	body, err := ioutil.ReadFile(filepath.Join("testdata", fixture))
+	if err != nil {
+		t.Fatalf("unable to read fixture: %s", err)
+	}
+
+	queries := make(chan url.Values, 1)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		select {
+		case queries <- r.URL.Query():
+		default:
+		}
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(status)
+		w.Write(body)
+	}))
+	t.Cleanup(server.Close)
+	return server, queries
+}
+
+func TestGetMonthCalendarFixtures(t *testing.T) {
+	req := CalendarRequest{City: "Auckland", Country: "NewZealand", Offsets: "0,0,0,0,0", Month: time.January, Year: 2021, Params: Params{Method: 3}}
+
+	t.Run("test recorded calendar timings and timezone are parsed", func(t *testing.T) {
+		server, _ := fixtureServer(t, http.StatusOK, "calendar-auckland-2021-01.json")
+
+		got, err := newTestClient(server, 0).GetMonthCalendar(context.Background(), req)
+		if err != nil {
+			t.Fatalf("unexpected error: %s", err)
+		}
+
+		if got.Code != 200 || got.Status != "OK" {
+			t.Errorf("want 200 OK, got %d %s", got.Code, got.Status)
+		}
+		if len(got.Data) != 3 {
+			t.Fatalf("want %d days, got %d", 3, len(got.Data))
+		}
+		if got.Data[0].Meta.Timezone != "Pacific/Auckland" {
+			t.Errorf("want %s, got %s", "Pacific/Auckland", got.Data[0].Meta.Timezone)
+		}
+		if got.Data[0].Date.Readable != "01 Jan 2021" {
+			t.Errorf("want %s, got %s", "01 Jan 2021", got.Data[0].Date.Readable)
+		}
+		if got.Data[0].Timings[Fajr] != "04:14 (NZDT)" {
+			t.Errorf("want %s, got %s", "04:14 (NZDT)", got.Data[0].Timings[Fajr])
+		}
+	})
+
+	t.Run("test untracked timings are filtered", func(t *testing.T) {
+		server, _ := fixtureServer(t, http.StatusOK, "calendar-auckland-2021-01.json")
+
+		got, err := newTestClient(server, 0).GetMonthCalendar(context.Background(), req)
+		if err != nil {
+			t.Fatalf("unexpected error: %s", err)
+		}
+
+		for _, day := range got.Data {
+			if len(day.Timings) != 9 {
+				t.Errorf("want 9 timings, got %v", day.Timings)
+			}
+			for _, adhan := range []Adhan{Fajr, Dhuhr, Asr, Maghrib, Isha, Imsak, Sunrise, Sunset, Midnight} {
+				if _, ok := day.Timings[adhan]; !ok {
+					t.Errorf("want %s adhan, got %v", adhan, day.Timings)
+				}
+			}
+		}
+	})
+
+	t.Run("test offsets are sent as tune parameter", func(t *testing.T) {
+		server, queries := fixtureServer(t, http.StatusOK, "calendar-auckland-2021-01-tuned.json")
+
+		tunedReq := req
+		tunedReq.Offsets = "5, 0, 0, 0, -3"
+		got, err := newTestClient(server, 0).GetMonthCalendar(context.Background(), tunedReq)
+		if err != nil {
+			t.Fatalf("unexpected error: %s", err)
+		}
+
+		wantTune := "0,5,0,0,0,0,0,-3"
+		if gotTune := (<-queries).Get("tune"); gotTune != wantTune {
+			t.Errorf("want tune %s, got %s", wantTune, gotTune)
+		}
+
+		// offsets are returned as either numbers or strings
+		offset := got.Data[0].Meta.Offset
+		if offset[Fajr] != float64(5) || offset[Isha] != "-3" {
+			t.Errorf("want fajr offset 5 and isha offset -3, got %v", offset)
+		}
+	})
+
+	t.Run("test error responses return status error", func(t *testing.T) {
+		for status, fixture := range map[int]string{
+			http.StatusBadRequest:      "error-bad-request.json",
+			http.StatusTooManyRequests: "error-rate-limited.json",
+		} {
+			server, _ := fixtureServer(t, status, fixture)
+
+			_, err := newTestClient(server, 0).GetMonthCalendar(context.Background(), req)
+
+			var statusErr *StatusError
+			if !errors.As(err, &statusErr) {
+				t.Fatalf("want StatusError, got %v", err)
+			}
+			if statusErr.Code != int64(status) {
+				t.Errorf("want code %d, got %d", status, statusErr.Code)
+			}
+		}
+	})
+
+	t.Run("test non-200 code in response body returns status error", func(t *testing.T) {
+		server, _ := fixtureServer(t, http.StatusOK, "error-rate-limited.json")
+
+		_, err := newTestClient(server, 0).GetMonthCalendar(context.Background(), req)
+
+		var statusErr *StatusError
+		if !errors.As(err, &statusErr) || statusErr.Code != http.StatusTooManyRequests {
+			t.Errorf("want StatusError with code %d, got %v", http.StatusTooManyRequests, err)
+		}
+	})
+
+	t.Run("test malformed payloads return decode error", func(t *testing.T) {
+		for _, fixture := range []string{"malformed-data.json", "malformed-truncated.json", "malformed-missing-code.json"} {
+			server, _ := fixtureServer(t, http.StatusOK, fixture)
+
+			_, err := newTestClient(server, 0).GetMonthCalendar(context.Background(), req)
+
+			var decodeErr *DecodeError
+			if !errors.As(err, &decodeErr) {
+				t.Errorf("want DecodeError for %s, got %v", fixture, err)
+			}
+		}
+	})
+
+	t.Run("test unreachable server returns request error", func(t *testing.T) {
+		server, _ := fixtureServer(t, http.StatusOK, "calendar-auckland-2021-01.json")
+		client := newTestClient(server, 0)
+		server.Close()
+
+		_, err := client.GetMonthCalendar(context.Background(), req)
+
+		var requestErr *RequestError
+		if !errors.As(err, &requestErr) {
+			t.Errorf("want RequestError, got %v", err)
+		}
+	})
+
+	t.Run("returns offset error for malformed offsets", func(t *testing.T) {
+		for _, offsets := range []string{"", "0,0,0,0", "0,0,0,0,0,0", "0,0,a,0,0"} {
+			offsetReq := req
+			offsetReq.Offsets = offsets
+			_, err := NewClient().GetMonthCalendar(context.Background(), offsetReq)
+
+			var offsetErr *OffsetError
+			if !errors.As(err, &offsetErr) {
+				t.Errorf("want OffsetError for offsets %q, got %v", offsets, err)
+			}
+		}
+	})
+}
+
+func TestCalendarURL(t *testing.T) {
+	t.Run("test city and country are url encoded", func(t *testing.T) {
+		got, err := NewClient().calendarURL(CalendarRequest{City: "Ho Chi Minh", Country: "Viet Nam", Offsets: "0,0,0,0,0", Month: 1, Year: 2021, Params: Params{Method: 3}})
+		if err != nil {
+			t.Fatalf("unexpected error: %s", err)
+		}
+
+		want := "http://api.aladhan.com/v1/calendarByCity?city=Ho+Chi+Minh&country=Viet+Nam&method=3&midnightMode=0&month=1&school=0&tune=0%2C0%2C0%2C0%2C0%2C0%2C0%2C0&year=2021"
+		if got != want {
+			t.Errorf("want %s, got %s", want, got)
+		}
+	})
+
+	t.Run("test coordinates take precedence over address", func(t *testing.T) {
+		got, err := NewClient().calendarURL(CalendarRequest{Address: "Auckland", Latitude: -36.8484597, Longitude: 174.7633315, Offsets: "0,0,0,0,0", Month: 1, Year: 2021})
+		if err != nil {
+			t.Fatalf("unexpected error: %s", err)
+		}
+
+		want := "http://api.aladhan.com/v1/calendar?latitude=-36.8484597&longitude=174.7633315&method=0&midnightMode=0&month=1&school=0&tune=0%2C0%2C0%2C0%2C0%2C0%2C0%2C0&year=2021"
+		if got != want {
+			t.Errorf("want %s, got %s", want, got)
+		}
+	})
+
+	t.Run("test address takes precedence over city", func(t *testing.T) {
+		got, err := NewClient().calendarURL(CalendarRequest{Address: "Sultanahmet Mosque, Istanbul, Turkey", City: "Auckland", Offsets: "0,0,0,0,0", Month: 1, Year: 2021, Params: Params{Method: 99, MethodSettings: "18.5,null,90 min"}})
+		if err != nil {
+			t.Fatalf("unexpected error: %s", err)
+		}
+
+		want := "http://api.aladhan.com/v1/calendarByAddress?address=Sultanahmet+Mosque%2C+Istanbul%2C+Turkey&method=99&methodSettings=18.5%2Cnull%2C90+min&midnightMode=0&month=1&school=0&tune=0%2C0%2C0%2C0%2C0%2C0%2C0%2C0&year=2021"
+		if got != want {
+			t.Errorf("want %s, got %s", want, got)
+		}
+	})
+
+	t.Run("test hijri adjustment is set when non-zero", func(t *testing.T) {
+		got, err := NewClient().calendarURL(CalendarRequest{City: "Auckland", Country: "NewZealand", Offsets: "0,0,0,0,0", Month: 1, Year: 2021, Params: Params{Method: 3, HijriAdjustment: -1}})
+		if err != nil {
+			t.Fatalf("unexpected error: %s", err)
+		}
+
+		want := "http://api.aladhan.com/v1/calendarByCity?adjustment=-1&city=Auckland&country=NewZealand&method=3&midnightMode=0&month=1&school=0&tune=0%2C0%2C0%2C0%2C0%2C0%2C0%2C0&year=2021"
+		if got != want {
+			t.Errorf("want %s, got %s", want, got)
+		}
+	})
+}
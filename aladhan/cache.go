@@ -0,0 +1,110 @@
+package aladhan
+
+import (
+	"crypto/sha1"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/zees-dev/prayeralarm/internal/atomicfile"
+)
+
+// Cache persists monthly calendar responses as JSON files in a directory
+type Cache struct {
+	Dir string
+}
+
+// NewCache returns a calendar cache persisting to the directory
+func NewCache(dir string) *Cache {
+	return &Cache{Dir: dir}
+}
+
+// cacheVersion is the version of the cache key fields (see `cacheKey`); it is incremented when the fields change,
+// so that calendars cached for other versions are ignored and pruned
+const cacheVersion = 1
+
+// cacheFilePattern matches the cache files of all versions (unversioned files predate `cacheVersion`)
+var cacheFilePattern = regexp.MustCompile(`^\d{4}-\d{2}-(v\d+-)?[0-9a-f]{12}\.json$`)
+
+// cacheKey returns the key fields of the request identifying its calendar, excluding the month and year;
+// the location, calculation parameters and offsets
+func cacheKey(req CalendarRequest) []string {
+	float := func(f float64) string { return strconv.FormatFloat(f, 'f', -1, 64) }
+	return []string{
+		req.City,
+		req.Country,
+		req.Address,
+		float(req.Latitude),
+		float(req.Longitude),
+		req.Offsets,
+		strconv.Itoa(req.Params.Method),
+		req.Params.MethodSettings,
+		strconv.Itoa(req.Params.School),
+		strconv.Itoa(req.Params.LatitudeAdjustmentMethod),
+		strconv.Itoa(req.Params.MidnightMode),
+		strconv.Itoa(req.Params.HijriAdjustment),
+	}
+}
+
+// path returns the cache file path of the request; keyed by month, cache version and a hash of the key fields
+func (c *Cache) path(req CalendarRequest) string {
+	hash := sha1.Sum([]byte(strings.Join(cacheKey(req), "\x00")))
+	filename := fmt.Sprintf("%04d-%02d-v%d-%s.json", req.Year, req.Month, cacheVersion, hex.EncodeToString(hash[:6]))
+	return filepath.Join(c.Dir, filename)
+}
+
+// Prune removes the cached calendars of other cache versions, which are never read
+func (c *Cache) Prune() error {
+	files, err := ioutil.ReadDir(c.Dir)
+	if err != nil {
+		return err
+	}
+	version := fmt.Sprintf("-v%d-", cacheVersion)
+	for _, file := range files {
+		if file.IsDir() || !cacheFilePattern.MatchString(file.Name()) || strings.Contains(file.Name(), version) {
+			continue
+		}
+		if err := os.Remove(filepath.Join(c.Dir, file.Name())); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Get returns the cached calendar response of the request
+func (c *Cache) Get(req CalendarRequest) (MonthlyAdhanCalenderResponse, error) {
+	var monthlyCalendarResp MonthlyAdhanCalenderResponse
+
+	data, err := ioutil.ReadFile(c.path(req))
+	if err != nil {
+		return monthlyCalendarResp, err
+	}
+	if err := json.Unmarshal(data, &monthlyCalendarResp); err != nil {
+		return monthlyCalendarResp, &DecodeError{Err: err}
+	}
+	return monthlyCalendarResp, nil
+}
+
+// Put persists the calendar response of the request; the file is replaced atomically and the calendars of
+// other cache versions are pruned
+func (c *Cache) Put(req CalendarRequest, monthlyCalendarResp MonthlyAdhanCalenderResponse) error {
+	if err := os.MkdirAll(c.Dir, 0755); err != nil {
+		return err
+	}
+
+	data, err := json.Marshal(monthlyCalendarResp)
+	if err != nil {
+		return err
+	}
+
+	if err := atomicfile.WriteFile(c.path(req), data, 0644); err != nil {
+		return err
+	}
+	return c.Prune()
+}
@@ -0,0 +1,85 @@
+package aladhan
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestCache(t *testing.T) {
+	req := CalendarRequest{City: "Auckland", Country: "NewZealand", Offsets: "0,0,0,0,0", Month: time.January, Year: 2021, Params: Params{Method: 3}}
+
+	t.Run("test cached calendar is returned for the same request", func(t *testing.T) {
+		cache := NewCache(t.TempDir())
+
+		want := MonthlyAdhanCalenderResponse{Code: 200, Status: "OK"}
+		if err := cache.Put(req, want); err != nil {
+			t.Fatalf("unexpected error: %s", err)
+		}
+
+		got, err := cache.Get(req)
+		if err != nil {
+			t.Fatalf("unexpected error: %s", err)
+		}
+		if got.Code != want.Code || got.Status != want.Status {
+			t.Errorf("want %+v, got %+v", want, got)
+		}
+	})
+
+	t.Run("test calendar is keyed by month, location and method", func(t *testing.T) {
+		cache := NewCache(t.TempDir())
+		if err := cache.Put(req, MonthlyAdhanCalenderResponse{Code: 200}); err != nil {
+			t.Fatalf("unexpected error: %s", err)
+		}
+
+		nextMonth := req
+		nextMonth.Month = time.February
+
+		otherCity := req
+		otherCity.City = "Wellington"
+
+		otherMethod := req
+		otherMethod.Params.Method = 2
+
+		for _, other := range []CalendarRequest{nextMonth, otherCity, otherMethod} {
+			if _, err := cache.Get(other); err == nil {
+				t.Errorf("want cache miss for %+v, got hit", other)
+			}
+		}
+	})
+	t.Run("test calendars of other cache versions are ignored and pruned", func(t *testing.T) {
+		dir := t.TempDir()
+		stale := []string{"2021-01-0123456789ab.json", "2021-01-v0-0123456789ab.json"}
+		for _, name := range append(stale, "notes.json") {
+			if err := ioutil.WriteFile(filepath.Join(dir, name), []byte(`{"code": 200}`), 0644); err != nil {
+				t.Fatalf("unexpected error: %s", err)
+			}
+		}
+
+		cache := NewCache(dir)
+		if _, err := cache.Get(req); err == nil {
+			t.Error("want cache miss, got hit")
+		}
+		if err := cache.Put(req, MonthlyAdhanCalenderResponse{Code: 200}); err != nil {
+			t.Fatalf("unexpected error: %s", err)
+		}
+		for _, name := range stale {
+			if _, err := os.Stat(filepath.Join(dir, name)); !os.IsNotExist(err) {
+				t.Errorf("want %s to be pruned, got %v", name, err)
+			}
+		}
+		if _, err := os.Stat(filepath.Join(dir, "notes.json")); err != nil {
+			t.Errorf("want unrelated file to be kept, got %s", err)
+		}
+	})
+
+	t.Run("test cache key is stable", func(t *testing.T) {
+		// Changing the key fields (or their encoding) must increment cacheVersion
+		want := filepath.Join("cache", "2021-01-v1-7754474a4c87.json")
+		if got := NewCache("cache").path(req); got != want {
+			t.Errorf("want %s, got %s", want, got)
+		}
+	})
+}
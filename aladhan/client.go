@@ -0,0 +1,70 @@
+package aladhan
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"time"
+)
+
+// DefaultBaseURL is the base URL of the public Adhan API
+const DefaultBaseURL = "http://api.aladhan.com/v1"
+
+// RetryPolicy defines how failed API requests are retried; the backoff doubles
+// after every attempt starting from `InitialBackoff`, up to `MaxBackoff`
+type RetryPolicy struct {
+	MaxRetries     int
+	InitialBackoff time.Duration
+	MaxBackoff     time.Duration
+}
+
+// backoff returns the wait period prior to retrying the (zero based) attempt
+func (rp RetryPolicy) backoff(attempt int) time.Duration {
+	backoff := rp.InitialBackoff
+	for i := 0; i < attempt && backoff < rp.MaxBackoff; i++ {
+		backoff *= 2
+	}
+	if rp.MaxBackoff > 0 && backoff > rp.MaxBackoff {
+		return rp.MaxBackoff
+	}
+	return backoff
+}
+
+// Client is an Adhan API client
+type Client struct {
+	BaseURL    string
+	HTTPClient *http.Client
+	Retry      RetryPolicy
+}
+
+// NewClient returns an Adhan API client for the public API with a 30 second timeout,
+// retrying failed requests 3 times with backoff from 1 second
+func NewClient() *Client {
+	return &Client{
+		BaseURL:    DefaultBaseURL,
+		HTTPClient: &http.Client{Timeout: 30 * time.Second},
+		Retry: RetryPolicy{
+			MaxRetries:     3,
+			InitialBackoff: time.Second,
+			MaxBackoff:     30 * time.Second,
+		},
+	}
+}
+
+// DefaultClient is the client used by `GetMonthCalendar`
+var DefaultClient = NewClient()
+
+// isRetryable reports whether a failed request may succeed when retried;
+// network failures (other than cancellation), rate limiting and server errors are retried
+func isRetryable(err error) bool {
+	var requestErr *RequestError
+	if errors.As(err, &requestErr) {
+		return !errors.Is(err, context.Canceled)
+	}
+
+	var statusErr *StatusError
+	if errors.As(err, &statusErr) {
+		return statusErr.Code == http.StatusTooManyRequests || statusErr.Code >= http.StatusInternalServerError
+	}
+	return false
+}
@@ -0,0 +1,93 @@
+package aladhan
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// newTestClient returns a client for the test server which retries without backoff
+func newTestClient(server *httptest.Server, maxRetries int) *Client {
+	client := NewClient()
+	client.BaseURL = server.URL
+	client.HTTPClient = server.Client()
+	client.Retry = RetryPolicy{MaxRetries: maxRetries, InitialBackoff: time.Millisecond, MaxBackoff: time.Millisecond}
+	return client
+}
+
+func TestRetryPolicy(t *testing.T) {
+	t.Run("test backoff doubles up to max backoff", func(t *testing.T) {
+		rp := RetryPolicy{InitialBackoff: time.Second, MaxBackoff: 5 * time.Second}
+		for attempt, want := range []time.Duration{time.Second, 2 * time.Second, 4 * time.Second, 5 * time.Second, 5 * time.Second} {
+			if got := rp.backoff(attempt); got != want {
+				t.Errorf("attempt %d: want %s, got %s", attempt, want, got)
+			}
+		}
+	})
+}
+
+func TestClientGetMonthCalendar(t *testing.T) {
+	req := CalendarRequest{City: "Auckland", Country: "NewZealand", Offsets: "0,0,0,0,0", Month: time.January, Year: 2021, Params: Params{Method: 3}}
+
+	t.Run("test server errors are retried", func(t *testing.T) {
+		var calls int32
+		server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if atomic.AddInt32(&calls, 1) < 3 {
+				w.WriteHeader(http.StatusServiceUnavailable)
+				return
+			}
+			fmt.Fprint(w, `{"code":200,"status":"OK","data":[]}`)
+		}))
+		defer server.Close()
+
+		got, err := newTestClient(server, 3).GetMonthCalendar(context.Background(), req)
+		if err != nil {
+			t.Fatalf("unexpected error: %s", err)
+		}
+		if got.Code != 200 {
+			t.Errorf("want %d, got %d", 200, got.Code)
+		}
+		if calls != 3 {
+			t.Errorf("want %d calls, got %d", 3, calls)
+		}
+	})
+
+	t.Run("test client errors are not retried", func(t *testing.T) {
+		var calls int32
+		server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			atomic.AddInt32(&calls, 1)
+			w.WriteHeader(http.StatusBadRequest)
+		}))
+		defer server.Close()
+
+		_, err := newTestClient(server, 3).GetMonthCalendar(context.Background(), req)
+
+		var statusErr *StatusError
+		if !errors.As(err, &statusErr) || statusErr.Code != http.StatusBadRequest {
+			t.Errorf("want StatusError with code %d, got %v", http.StatusBadRequest, err)
+		}
+		if calls != 1 {
+			t.Errorf("want %d calls, got %d", 1, calls)
+		}
+	})
+
+	t.Run("test cancelled context stops retries", func(t *testing.T) {
+		server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			w.WriteHeader(http.StatusServiceUnavailable)
+		}))
+		defer server.Close()
+
+		ctx, cancel := context.WithCancel(context.Background())
+		cancel()
+
+		_, err := newTestClient(server, 3).GetMonthCalendar(ctx, req)
+		if !errors.Is(err, context.Canceled) {
+			t.Errorf("want %v, got %v", context.Canceled, err)
+		}
+	})
+}
@@ -0,0 +1,49 @@
+package aladhan
+
+import "fmt"
+
+// RequestError is returned when the API request could not be completed (i.e. network failure)
+type RequestError struct {
+	URL string
+	Err error
+}
+
+func (e *RequestError) Error() string {
+	return fmt.Sprintf("API request to URL %s failed: %s", e.URL, e.Err)
+}
+
+func (e *RequestError) Unwrap() error {
+	return e.Err
+}
+
+// StatusError is returned when the API responds with a non-200 status code
+type StatusError struct {
+	Code   int64
+	Status string
+}
+
+func (e *StatusError) Error() string {
+	return fmt.Sprintf("API responded with code %d: %s", e.Code, e.Status)
+}
+
+// DecodeError is returned when the API response could not be decoded into `MonthlyAdhanCalenderResponse`
+type DecodeError struct {
+	Err error
+}
+
+func (e *DecodeError) Error() string {
+	return fmt.Sprintf("failed to decode API response, incorrect struct formatting and/or field type(s): %s", e.Err)
+}
+
+func (e *DecodeError) Unwrap() error {
+	return e.Err
+}
+
+// OffsetError is returned when the adhan offsets string is not 5 comma separated integers
+type OffsetError struct {
+	Offsets string
+}
+
+func (e *OffsetError) Error() string {
+	return fmt.Sprintf(`invalid adhan offsets "%s"; 5 comma separated integers (in mins) are required`, e.Offsets)
+}
@@ -14,6 +14,15 @@ type MonthlyAdhanCalenderResponse struct {
 		Date    struct {
 			Readable  string `json:"readable"`
 			Timestamp string `json:"timestamp"`
+			Hijri     struct {
+				Day   string `json:"day"`
+				Month struct {
+					Number int    `json:"number"`
+					En     string `json:"en"`
+					Ar     string `json:"ar"`
+				} `json:"month"`
+				Year string `json:"year"`
+			} `json:"hijri"`
 		} `json:"date"`
 		Meta struct {
 			Latitude  float64               `json:"latitude"`
@@ -41,3 +50,28 @@ const (
 	Maghrib Adhan = "Maghrib"
 	Isha    Adhan = "Isha"
 )
+
+// Jumuah is the Friday prayer, which takes the place of Dhuhr on Fridays; it is not a timing of the Adhan API
+const Jumuah Adhan = "Jumuah"
+
+// Non-adhan timings
+const (
+	Imsak    Adhan = "Imsak"
+	Sunrise  Adhan = "Sunrise"
+	Sunset   Adhan = "Sunset"
+	Midnight Adhan = "Midnight"
+)
+
+// Tahajjud is the night prayer in the last third of the night (or from islamic midnight);
+// it is not a timing of the Adhan API
+const Tahajjud Adhan = "Tahajjud"
+
+// IsPrayer reports whether the timing is one of the 5 daily adhans (or Jumuah)
+func (a Adhan) IsPrayer() bool {
+	switch a {
+	case Fajr, Dhuhr, Asr, Maghrib, Isha, Jumuah:
+		return true
+	default:
+		return false
+	}
+}
@@ -1,13 +1,112 @@
+type Adhan = "Fajr" | "Dhuhr" | "Jumuah" | "Asr" | "Maghrib" | "Isha" | "Imsak" | "Sunrise" | "Sunset" | "Midnight" | "Tahajjud"
 
-type Adhan = "Fajr" | "Dhuhr" | "Asr" | "Maghrib" | "Isha"
-
+export interface SettingsResponse {
+    settings: Settings
+    location: Location
+}
+export interface Settings {
+    location: Location
+    calculation: Calculation
+    offsets: string
+    hijriAdjustment: number
+    jumuah: Jumuah
+    ramadan: Ramadan
+    tahajjud: "" | "last-third" | "midnight"
+    playback: PlaybackPolicy
+}
+type PlaybackMode = "full" | "short" | "notify" | "mute"
+export interface PlaybackPolicy {
+    modes: Partial<Record<Adhan, PlaybackMode>>
+    volumes: Partial<Record<Adhan, number>>
+    quietHours?: QuietHours
+    fadeIn: string // duration; i.e. "1m30s"
+    fadeOut: string // duration; i.e. "1m30s"
+    timeout: string // duration; i.e. "1m30s"
+}
+export interface QuietHours {
+    from: string
+    to: string
+    mode: PlaybackMode
+    volume: number
+}
+export interface Ramadan {
+    mode: "auto" | "on" | "off"
+    suhoorLeadTime: number
+}
+export interface Jumuah {
+    enabled: boolean
+    time?: string
+    suppressDhuhr: boolean
+}
+export interface Location {
+    city: string
+    country: string
+    address: string
+    latitude: number
+    longitude: number
+    elevation: number
+    timezone: string
+}
+export interface Calculation {
+    method: Method
+    school: "standard" | "hanafi"
+    highLatitude: "none" | "middle" | "seventh" | "angle"
+    midnight: "standard" | "jafari"
+}
+export interface Method {
+    id: number
+    name: string
+    fajr: number
+    isha: number
+    ishaMinutes?: number
+    maghrib?: number
+    maghribMinutes?: number
+}
 export interface Timing {
     date: string
+    hijri: HijriDate
     prayers: PrayerCall[]
+    extras: PrayerCall[]
 }
 export interface PrayerCall {
+    id: string
     play: boolean
     time: string
     type: Adhan
+    alerts?: Alert[]
+    /** @deprecated use `id` */
     index: number
 }
+export interface FastingCountdown {
+    ramadan: boolean
+    fasting: boolean
+    next?: "suhoor" | "iftar"
+    time?: string
+    seconds: number
+}
+export interface HijriDate {
+    day: number
+    month: HijriMonth
+    year: number
+}
+export interface HijriMonth {
+    number: number
+    en: string
+    ar: string
+}
+export interface Alert {
+    id: string
+    type: "reminder" | "iqamah" | "suhoor" | "iftar"
+    time: string
+    play: boolean
+}
+export interface AudioResponse {
+    keys: string[]
+    tracks: Track[]
+    assignments: Record<string, string>
+}
+export interface Track {
+    name: string
+    duration: number
+    error?: string
+}
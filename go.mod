@@ -10,5 +10,4 @@ require (
 	github.com/hajimehoshi/go-mp3 v0.3.1
 	github.com/hajimehoshi/oto v0.6.1
 	github.com/olekukonko/tablewriter v0.0.4
-	golang.org/x/sync v0.0.0-20210220032951-036812b2e83c
 )
@@ -1,42 +1,174 @@
 package http
 
 import (
+	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"log"
 	"net/http"
 	"strconv"
+	"strings"
 	"time"
 
 	"github.com/gorilla/handlers"
 	"github.com/gorilla/mux"
+	"github.com/zees-dev/prayeralarm/aladhan"
 	"github.com/zees-dev/prayeralarm/prayer"
 )
 
+// settingsResponse is the JSON response of the settings endpoint
+type settingsResponse struct {
+	Settings settingsBody    `json:"settings"`
+	Location prayer.Location `json:"location"`
+}
+
+// settingsBody are the JSON settings, with the durations of the playback policy as duration strings
+type settingsBody struct {
+	prayer.Settings
+	Playback playbackBody `json:"playback"`
+}
+
+// playbackBody is the JSON request and response body of the playback endpoints
+type playbackBody struct {
+	Modes      map[aladhan.Adhan]prayer.PlaybackMode `json:"modes"`
+	Volumes    map[aladhan.Adhan]int                 `json:"volumes"`
+	QuietHours *prayer.QuietHours                    `json:"quietHours,omitempty"`
+	FadeIn     duration                              `json:"fadeIn"`
+	FadeOut    duration                              `json:"fadeOut"`
+	Timeout    duration                              `json:"timeout"`
+}
+
+func newSettingsBody(settings prayer.Settings) settingsBody {
+	return settingsBody{Settings: settings, Playback: newPlaybackBody(settings.Playback)}
+}
+
+func newPlaybackBody(policy prayer.PlaybackPolicy) playbackBody {
+	return playbackBody{
+		Modes:      policy.Modes,
+		Volumes:    policy.Volumes,
+		QuietHours: policy.QuietHours,
+		FadeIn:     duration(policy.FadeIn),
+		FadeOut:    duration(policy.FadeOut),
+		Timeout:    duration(policy.Timeout),
+	}
+}
+
+func (pb playbackBody) policy() prayer.PlaybackPolicy {
+	return prayer.PlaybackPolicy{
+		Modes:      pb.Modes,
+		Volumes:    pb.Volumes,
+		QuietHours: pb.QuietHours,
+		FadeIn:     time.Duration(pb.FadeIn),
+		FadeOut:    time.Duration(pb.FadeOut),
+		Timeout:    time.Duration(pb.Timeout),
+	}
+}
+
+// cueBody is the JSON response of a cue, with its fades as duration strings
+type cueBody struct {
+	prayer.Cue
+	FadeIn  duration `json:"fadeIn,omitempty"`
+	FadeOut duration `json:"fadeOut,omitempty"`
+}
+
+// duration is encoded in JSON as a duration string (i.e. "1m30s"), and decoded from a duration string or a number
+// of seconds (i.e. 90)
+type duration time.Duration
+
+func (d duration) MarshalJSON() ([]byte, error) {
+	return json.Marshal(time.Duration(d).String())
+}
+
+func (d *duration) UnmarshalJSON(data []byte) error {
+	var value interface{}
+	if err := json.Unmarshal(data, &value); err != nil {
+		return err
+	}
+	switch value := value.(type) {
+	case float64:
+		*d = duration(value * float64(time.Second))
+	case string:
+		parsed, err := time.ParseDuration(value)
+		if err != nil {
+			return err
+		}
+		*d = duration(parsed)
+	default:
+		return fmt.Errorf("invalid duration %s; must be a duration string (i.e. \"5s\") or number of seconds", data)
+	}
+	return nil
+}
+
+// audioResponse is the JSON response of the audio library endpoints
+type audioResponse struct {
+	Keys        []string          `json:"keys"`
+	Tracks      []prayer.Track    `json:"tracks"`
+	Assignments map[string]string `json:"assignments"`
+}
+
+// assignmentRequest is the JSON request body of the audio assignment endpoint
+type assignmentRequest struct {
+	Track string `json:"track"`
+}
+
+// renameRequest is the JSON request body of the track rename endpoint
+type renameRequest struct {
+	Name string `json:"name"`
+}
+
+// uploadFormField is the multipart form field of uploaded tracks
+const uploadFormField = "file"
+
+// trackRoutePrefix is the path prefix of the audio library track routes, which upload and preview tracks
+const trackRoutePrefix = "/api/audio/tracks"
+
+const (
+	// requestTimeout is the maximum duration of handling requests, other than those of track routes
+	requestTimeout = 15 * time.Second
+	// trackTransferTimeout is the maximum duration of reading and writing requests; long enough for tracks of up to
+	// `prayer.MaxTrackSize` to be uploaded (or previewed) over slow connections
+	trackTransferTimeout = 5 * time.Minute
+)
+
 type server struct {
-	router    *mux.Router
-	prayerSvc prayer.PrayerService
+	router       *mux.Router
+	prayerSvc    prayer.PrayerService
+	audioLibrary *prayer.AudioLibrary
+	httpServer   *http.Server
 }
 
-func NewServer(prayerSvc prayer.PrayerService) *server {
+func NewServer(prayerSvc prayer.PrayerService, audioLibrary *prayer.AudioLibrary) *server {
 	s := &server{
-		router:    mux.NewRouter(),
-		prayerSvc: prayerSvc,
+		router:       mux.NewRouter(),
+		prayerSvc:    prayerSvc,
+		audioLibrary: audioLibrary,
 	}
 	s.initializeRoutes()
+	s.httpServer = &http.Server{
+		Handler:           handlers.CORS()(loggedHandler(timeoutHandler(s.router))),
+		ReadHeaderTimeout: requestTimeout,
+		ReadTimeout:       trackTransferTimeout,
+		WriteTimeout:      trackTransferTimeout,
+	}
 	return s
 }
 
-func (s *server) Run(port uint) {
-	httpServer := &http.Server{
-		Handler:      handlers.CORS()(loggedHandler(s.router)),
-		Addr:         fmt.Sprintf("0.0.0.0:%d", port),
-		WriteTimeout: 15 * time.Second,
-		ReadTimeout:  15 * time.Second,
-	}
+// Run serves HTTP requests on the port until the server is shut down
+func (s *server) Run(port uint) error {
+	s.httpServer.Addr = fmt.Sprintf("0.0.0.0:%d", port)
 
 	log.Printf("Running prayeralarm server on port %d...", port)
-	log.Fatal(httpServer.ListenAndServe())
+	if err := s.httpServer.ListenAndServe(); err != http.ErrServerClosed {
+		return err
+	}
+	return nil
+}
+
+// Shutdown stops the server from accepting requests and waits for active requests to complete,
+// until the context is done
+func (s *server) Shutdown(ctx context.Context) error {
+	return s.httpServer.Shutdown(ctx)
 }
 
 func loggedHandler(next http.Handler) http.Handler {
@@ -52,12 +184,41 @@ func loggedHandler(next http.Handler) http.Handler {
 	})
 }
 
+// timeoutHandler responds with 503 Service Unavailable to requests which are not handled within `requestTimeout`;
+// requests of track routes are bound by the server `trackTransferTimeout` instead
+func timeoutHandler(next http.Handler) http.Handler {
+	timeout := http.TimeoutHandler(next, requestTimeout, "request timed out")
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if strings.HasPrefix(r.URL.Path, trackRoutePrefix) {
+			next.ServeHTTP(w, r)
+			return
+		}
+		timeout.ServeHTTP(w, r)
+	})
+}
+
 func (s *server) initializeRoutes() {
 	s.router.HandleFunc("/api/health", s.healthHandler).Methods(http.MethodGet)
+	s.router.HandleFunc("/api/settings", s.settingsHandler).Methods(http.MethodGet)
 	s.router.HandleFunc("/api/timings", s.timingsHandler).Methods(http.MethodGet)
+	s.router.HandleFunc("/api/prayers/{id}/toggle", s.prayerToggleHandler).Methods(http.MethodPost)
+	s.router.HandleFunc("/api/alerts/{id}/toggle", s.alertToggleHandler).Methods(http.MethodPost)
+	// Deprecated: use /api/prayers/{id}/toggle
 	s.router.HandleFunc("/api/timings/toggle/{index}", s.timingsUpdateHandler).Methods(http.MethodPost)
 	s.router.HandleFunc("/api/timings/off", s.timingsTurnOffHandler).Methods(http.MethodPost)
 	s.router.HandleFunc("/api/timings/on", s.timingsTurnOnHandler).Methods(http.MethodPost)
+	s.router.HandleFunc("/api/schedule/next", s.nextEventHandler).Methods(http.MethodGet)
+	s.router.HandleFunc("/api/ramadan", s.ramadanHandler).Methods(http.MethodGet)
+	s.router.HandleFunc("/api/playback", s.playbackHandler).Methods(http.MethodGet)
+	s.router.HandleFunc("/api/playback", s.playbackUpdateHandler).Methods(http.MethodPut)
+	s.router.HandleFunc("/api/playback/stop", s.playbackStopHandler).Methods(http.MethodPost)
+	s.router.HandleFunc("/api/audio", s.audioHandler).Methods(http.MethodGet)
+	s.router.HandleFunc("/api/audio/assignments/{key}", s.audioAssignHandler).Methods(http.MethodPut)
+	s.router.HandleFunc(trackRoutePrefix, s.tracksHandler).Methods(http.MethodGet)
+	s.router.HandleFunc(trackRoutePrefix, s.trackUploadHandler).Methods(http.MethodPost)
+	s.router.HandleFunc(trackRoutePrefix+"/{name}", s.trackPreviewHandler).Methods(http.MethodGet)
+	s.router.HandleFunc(trackRoutePrefix+"/{name}", s.trackRenameHandler).Methods(http.MethodPatch)
+	s.router.HandleFunc(trackRoutePrefix+"/{name}", s.trackDeleteHandler).Methods(http.MethodDelete)
 	s.router.PathPrefix("/").Handler(http.FileServer(http.Dir("client/public")))
 }
 
@@ -78,7 +239,47 @@ func (s *server) timingsHandler(w http.ResponseWriter, r *http.Request) {
 	json.NewEncoder(w).Encode(s.prayerSvc.GetPrayerTimings())
 }
 
+func (s *server) settingsHandler(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(settingsResponse{
+		Settings: newSettingsBody(s.prayerSvc.GetSettings()),
+		Location: s.prayerSvc.GetLocation(),
+	})
+}
+
+func (s *server) prayerToggleHandler(w http.ResponseWriter, r *http.Request) {
+	id := mux.Vars(r)["id"]
+
+	prayer, err := s.prayerSvc.ToggleAdhan(id)
+	if err != nil {
+		http.Error(w, fmt.Sprintf("error toggling adhan; err=%s", err), http.StatusNotFound)
+		return
+	}
+
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(prayer)
+}
+
+func (s *server) alertToggleHandler(w http.ResponseWriter, r *http.Request) {
+	id := mux.Vars(r)["id"]
+
+	alert, err := s.prayerSvc.ToggleAlert(id)
+	if err != nil {
+		http.Error(w, fmt.Sprintf("error toggling alert; err=%s", err), http.StatusNotFound)
+		return
+	}
+
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(alert)
+}
+
+// timingsUpdateHandler toggles the adhan by its positional index
+//
+// Deprecated: indexes are not stable across restarts and month rollovers; use prayerToggleHandler
 func (s *server) timingsUpdateHandler(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Deprecation", "true")
+	w.Header().Set("Link", `</api/prayers/{id}/toggle>; rel="successor-version"`)
+
 	params := mux.Vars(r)
 	index, ok := params["index"]
 	if !ok {
@@ -92,7 +293,7 @@ func (s *server) timingsUpdateHandler(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	prayer, err := s.prayerSvc.ToggleAdhan(intIndex)
+	prayer, err := s.prayerSvc.ToggleAdhanByIndex(intIndex)
 	if err != nil {
 		http.Error(w, fmt.Sprintf("error toggling adhan; err=%s", err), http.StatusBadRequest)
 		return
@@ -113,3 +314,182 @@ func (s *server) timingsTurnOnHandler(w http.ResponseWriter, r *http.Request) {
 	w.WriteHeader(http.StatusOK)
 	json.NewEncoder(w).Encode(s.prayerSvc.GetPrayerTimings())
 }
+
+func (s *server) nextEventHandler(w http.ResponseWriter, r *http.Request) {
+	event, ok := s.prayerSvc.NextEvent()
+	if !ok {
+		http.Error(w, "no events scheduled", http.StatusNotFound)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(event)
+}
+
+// playbackHandler returns the playback policy; i.e. the playback modes, volumes and fades of adhans
+func (s *server) playbackHandler(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(newPlaybackBody(s.prayerSvc.GetPlaybackPolicy()))
+}
+
+// playbackUpdateHandler updates the playback policy with the fields of the request body (i.e. `{"volumes": {"Fajr": 40}}`),
+// which apply to subsequently played adhans and alerts; durations are duration strings (i.e. `{"fadeIn": "5s"}`) or
+// numbers of seconds
+func (s *server) playbackUpdateHandler(w http.ResponseWriter, r *http.Request) {
+	body := newPlaybackBody(s.prayerSvc.GetPlaybackPolicy())
+	if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+		http.Error(w, fmt.Sprintf("invalid request body; err=%s", err), http.StatusBadRequest)
+		return
+	}
+	if err := s.prayerSvc.SetPlaybackPolicy(body.policy()); err != nil {
+		http.Error(w, fmt.Sprintf("error updating playback policy; err=%s", err), http.StatusBadRequest)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(body)
+}
+
+// playbackStopHandler stops the in-flight playback (i.e. the adhan being played), returning its cue
+func (s *server) playbackStopHandler(w http.ResponseWriter, r *http.Request) {
+	cue, err := s.prayerSvc.StopPlayback()
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusNotFound)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(cueBody{Cue: *cue, FadeIn: duration(cue.FadeIn), FadeOut: duration(cue.FadeOut)})
+}
+
+// ramadanHandler returns the countdown to the next start or end of the fast during Ramadan
+func (s *server) ramadanHandler(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(s.prayerSvc.GetFastingCountdown())
+}
+
+// audioHandler returns the tracks of the audio library and their assignment to cues
+func (s *server) audioHandler(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(s.audioResponse())
+}
+
+// audioAssignHandler assigns a track of the audio library to the cue key; i.e. `fajr` or `reminder`
+func (s *server) audioAssignHandler(w http.ResponseWriter, r *http.Request) {
+	key := mux.Vars(r)["key"]
+
+	var req assignmentRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, fmt.Sprintf("invalid request body; err=%s", err), http.StatusBadRequest)
+		return
+	}
+	if err := s.audioLibrary.Assign(key, req.Track); err != nil {
+		http.Error(w, fmt.Sprintf("error assigning audio; err=%s", err), audioErrorStatus(err))
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(s.audioResponse())
+}
+
+func (s *server) tracksHandler(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(s.audioLibrary.Tracks())
+}
+
+// trackUploadHandler adds the mp3 track of the multipart `file` form field to the audio library
+func (s *server) trackUploadHandler(w http.ResponseWriter, r *http.Request) {
+	// Allow for the multipart encoding overhead of the track
+	r.Body = http.MaxBytesReader(w, r.Body, prayer.MaxTrackSize+1<<20)
+	reader, err := r.MultipartReader()
+	if err != nil {
+		http.Error(w, fmt.Sprintf("multipart form required; err=%s", err), http.StatusBadRequest)
+		return
+	}
+
+	for {
+		part, err := reader.NextPart()
+		if err != nil {
+			http.Error(w, fmt.Sprintf("missing %s form field; err=%s", uploadFormField, err), http.StatusBadRequest)
+			return
+		}
+		if part.FormName() != uploadFormField {
+			continue
+		}
+
+		track, err := s.audioLibrary.Add(part.FileName(), part)
+		if err != nil {
+			http.Error(w, fmt.Sprintf("error uploading track; err=%s", err), audioErrorStatus(err))
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusCreated)
+		json.NewEncoder(w).Encode(track)
+		return
+	}
+}
+
+// trackPreviewHandler serves the mp3 track, to be previewed by the client
+func (s *server) trackPreviewHandler(w http.ResponseWriter, r *http.Request) {
+	path, err := s.audioLibrary.TrackPath(mux.Vars(r)["name"])
+	if err != nil {
+		http.Error(w, fmt.Sprintf("error previewing track; err=%s", err), audioErrorStatus(err))
+		return
+	}
+	w.Header().Set("Content-Type", "audio/mpeg")
+	http.ServeFile(w, r, path)
+}
+
+func (s *server) trackRenameHandler(w http.ResponseWriter, r *http.Request) {
+	var req renameRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, fmt.Sprintf("invalid request body; err=%s", err), http.StatusBadRequest)
+		return
+	}
+	if err := s.audioLibrary.Rename(mux.Vars(r)["name"], req.Name); err != nil {
+		http.Error(w, fmt.Sprintf("error renaming track; err=%s", err), audioErrorStatus(err))
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(s.audioResponse())
+}
+
+func (s *server) trackDeleteHandler(w http.ResponseWriter, r *http.Request) {
+	if err := s.audioLibrary.Delete(mux.Vars(r)["name"]); err != nil {
+		http.Error(w, fmt.Sprintf("error deleting track; err=%s", err), audioErrorStatus(err))
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// audioErrorStatus returns the HTTP status code of audio library errors
+func audioErrorStatus(err error) int {
+	switch {
+	case errors.Is(err, prayer.ErrTrackNotFound):
+		return http.StatusNotFound
+	case errors.Is(err, prayer.ErrTrackExists), errors.Is(err, prayer.ErrTrackAssigned):
+		return http.StatusConflict
+	case errors.Is(err, prayer.ErrInvalidTrack), errors.Is(err, prayer.ErrUndefinedAudioKey):
+		return http.StatusBadRequest
+	default:
+		return http.StatusInternalServerError
+	}
+}
+
+func (s *server) audioResponse() audioResponse {
+	return audioResponse{
+		Keys:        prayer.AudioKeys,
+		Tracks:      s.audioLibrary.Tracks(),
+		Assignments: s.audioLibrary.Assignments(),
+	}
+}
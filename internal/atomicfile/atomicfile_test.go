@@ -0,0 +1,38 @@
+package atomicfile
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestWriteFile(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "prayers.json")
+	if err := ioutil.WriteFile(path, []byte("old"), 0600); err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+
+	if err := WriteFile(path, []byte("new"), 0644); err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+
+	got, err := ioutil.ReadFile(path)
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	if string(got) != "new" {
+		t.Errorf("want new, got %s", got)
+	}
+	info, err := os.Stat(path)
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	if info.Mode().Perm() != 0644 {
+		t.Errorf("want %s, got %s", os.FileMode(0644), info.Mode().Perm())
+	}
+	if files, _ := ioutil.ReadDir(dir); len(files) != 1 {
+		t.Errorf("want temporary file to be removed, got %d files", len(files))
+	}
+}
@@ -1,22 +1,70 @@
 package main
 
 import (
+	"context"
 	"flag"
 	"log"
+	"os"
+	"os/signal"
+	"strings"
+	"syscall"
 	"time"
 
+	"github.com/zees-dev/prayeralarm/aladhan"
 	server "github.com/zees-dev/prayeralarm/http"
 	"github.com/zees-dev/prayeralarm/prayer"
+	"github.com/zees-dev/prayeralarm/praytime"
 )
 
+// serverShutdownTimeout is the period to let active HTTP requests complete on shutdown
+const serverShutdownTimeout = 5 * time.Second
+
 type cliFlags struct {
-	city    string
-	country string
-	offset  string
-	month   time.Month
-	year    int
-	port    uint
-	output  string
+	city       string
+	country    string
+	address    string
+	latitude   float64
+	longitude  float64
+	elevation  float64
+	timezone   string
+	provider   string
+	cacheDir   string
+	database   string
+	apiURL     string
+	apiTimeout time.Duration
+	apiRetries int
+	method     string
+	fajrAngle  float64
+	ishaAngle  float64
+	school     string
+	midnight   string
+	highLat    string
+	offset     string
+	hijriAdj   int
+	reminders  string
+	iqamah     string
+	iqamahCSV  string
+	jumuah     bool
+	jumuahTime string
+	suppress   bool
+	ramadan    string
+	suhoor     int
+	tahajjud   string
+	modes      string
+	volumes    string
+	quietHours string
+	quietMode  string
+	quietVol   int
+	fadeIn     time.Duration
+	fadeOut    time.Duration
+	playTime   time.Duration
+	month      time.Month
+	year       int
+	port       uint
+	output     string
+	command    string
+	audioDir   string
+	grace      time.Duration
 }
 
 func main() {
@@ -24,44 +72,326 @@ func main() {
 
 	cityPtr := flag.String("city", "Auckland", "city for which adhan timings are to be retrieved")
 	countryPtr := flag.String("country", "NewZealand", "country for which adhan timings are to be retrieved")
+	addressPtr := flag.String("address", "", "free-form address for which adhan timings are to be retrieved; takes precedence over city and country")
+	latitudePtr := flag.Float64("latitude", 0, "latitude for which adhan timings are to be retrieved; takes precedence over address")
+	longitudePtr := flag.Float64("longitude", 0, "longitude for which adhan timings are to be retrieved; takes precedence over address")
+	elevationPtr := flag.Float64("elevation", 0, "elevation (in meters) for which adhan timings are to be calculated")
+	timezonePtr := flag.String("timezone", "", "timezone of calculated adhan timings (e.g. `Pacific/Auckland`); defaults to local timezone")
+	providerPtr := flag.String("provider", string(prayer.ALADHAN), "adhan timings provider; supported options are `aladhan` and `calculation`")
+	cacheDirPtr := flag.String("cache-dir", "cache", "directory to cache retrieved adhan calendars; empty to disable caching")
+	databasePtr := flag.String("database", "", "file to persist adhan settings (i.e. muted adhans) to; empty to keep settings in memory")
+	apiURLPtr := flag.String("api-url", aladhan.DefaultBaseURL, "base URL of the adhan API (or a local mirror)")
+	apiTimeoutPtr := flag.Duration("api-timeout", 30*time.Second, "timeout of adhan API requests")
+	apiRetriesPtr := flag.Int("api-retries", 3, "number of retries (with exponential backoff) of failed adhan API requests")
+	methodPtr := flag.String("method", "MWL", "calculation method; supported options are `Jafari`, `Karachi`, `ISNA`, `MWL`, `Makkah`, `Egypt`, `Tehran`, `Gulf`, `Kuwait`, `Qatar`, `Singapore`, `France`, `Turkey`, `Russia` and `Custom`")
+	fajrAnglePtr := flag.Float64("fajr-angle", 18, "fajr angle of the `Custom` calculation method")
+	ishaAnglePtr := flag.Float64("isha-angle", 17, "isha angle of the `Custom` calculation method")
+	schoolPtr := flag.String("school", "standard", "juristic school for asr; supported options are `standard` and `hanafi`")
+	midnightPtr := flag.String("midnight", "", "midnight mode; supported options are `standard` and `jafari`; defaults to mode of calculation method")
+	highLatPtr := flag.String("high-latitude", "angle", "high latitude adjustment; supported options are `none` (calculation provider only), `middle`, `seventh` and `angle`")
 	offsetPtr := flag.String("offsets", "0,0,0,0,0", "comma seperated string of adhan offsets (in mins) for the 5 daily adhans (fajr, dhuhr, asr, maghrib, isha)")
+	remindersPtr := flag.String("reminders", "0,0,0,0,0", "comma seperated string of reminder lead times (in mins) prior to the 5 daily adhans; 0 for no reminder")
+	iqamahPtr := flag.String("iqamah", "0,0,0,0,0", "comma seperated string of iqamah rules for the 5 daily adhans; +N for N mins after the adhan, HH:MM for a fixed time, or 0 for no iqamah")
+	iqamahCSVPtr := flag.String("iqamah-table", "", "CSV file of iqamah times by date (with columns date,fajr,dhuhr,asr,maghrib,isha); takes precedence over iqamah rules")
+	jumuahPtr := flag.Bool("jumuah", false, "play the jumuah adhan in place of dhuhr on fridays")
+	jumuahTimePtr := flag.String("jumuah-time", "", "fixed khutbah time (HH:MM) of the jumuah adhan; defaults to the dhuhr time")
+	suppressPtr := flag.Bool("jumuah-suppress-dhuhr", false, "suppress the regular dhuhr adhan on fridays when jumuah is enabled")
+	ramadanPtr := flag.String("ramadan", string(prayer.AUTO), "ramadan mode of suhoor and iftar alerts; supported options are `auto` (detected from the hijri month), `on` and `off`")
+	suhoorPtr := flag.Int("suhoor-lead-time", 30, "lead time (in mins) of the suhoor alert prior to imsak during ramadan")
+	tahajjudPtr := flag.String("tahajjud", "", "night prayer alarm between maghrib and the next fajr; supported options are `last-third` and `midnight`; empty for no alarm")
+	modesPtr := flag.String("playback-modes", "full,full,full,full,full", "comma seperated string of playback modes for the 5 daily adhans (and their alerts); supported modes are `full`, `short`, `notify` and `mute`")
+	volumesPtr := flag.String("volumes", "100,100,100,100,100", "comma seperated string of playback volumes (in percent) for the 5 daily adhans (and their alerts)")
+	quietHoursPtr := flag.String("quiet-hours", "", "daily window (HH:MM-HH:MM) of quieter playback, i.e. `22:00-06:00`; empty for no quiet hours")
+	quietModePtr := flag.String("quiet-mode", string(prayer.SHORT), "playback mode during quiet hours; supported modes are `full`, `short`, `notify` and `mute`")
+	quietVolPtr := flag.Int("quiet-volume", 50, "playback volume (in percent) during quiet hours")
+	fadeInPtr := flag.Duration("fade-in", 0, "duration over which adhan (and alert) playback fades in; 0 for no fade in (native output and fading player commands only)")
+	fadeOutPtr := flag.Duration("fade-out", 0, "duration over which adhan (and alert) playback fades out; 0 for no fade out (native output and fading player commands only)")
+	playTimePtr := flag.Duration("playback-timeout", 10*time.Minute, "maximum duration of adhan (and alert) playback, after which it is stopped; 0 for no timeout")
+	hijriAdjPtr := flag.Int("hijri-adjustment", 0, "number of days (i.e. -1 or 1) to adjust hijri dates by, for local moon sighting")
 	yearPtr := flag.Int("year", year, "year of adhan playback")
 	monthPtr := flag.Int("month", int(month), "month of adhan playback")
-	outputPtr := flag.String("output", string(prayer.OMX), "output device; supported options are `stdout`, `native` and `omx`")
+	outputPtr := flag.String("output", string(prayer.OMX), "output device; supported options are `stdout`, `native`, `omx` and `command`")
+	commandPtr := flag.String("command", "mpv", "player command of the `command` output; a preset (`mpv`, `mpg123`, `ffplay`, `cvlc` or `omxplayer`) or a template, i.e. `mpv --volume={{.Volume}} {{.File}}`")
+	audioDirPtr := flag.String("audio-dir", "mp3", "audio library directory of mp3 tracks played for adhans and alerts")
 	portPtr := flag.Uint("port", 8080, "server port")
+	gracePtr := flag.Duration("shutdown-grace", 5*time.Second, "period to let in-flight adhan playback finish on shutdown before it is stopped; 0 to stop immediately")
 
 	flag.Parse()
 
 	cliFlags := cliFlags{
-		city:    *cityPtr,
-		country: *countryPtr,
-		offset:  *offsetPtr,
-		year:    *yearPtr,
-		month:   time.Month(*monthPtr),
-		output:  *outputPtr,
-		port:    *portPtr,
+		city:       *cityPtr,
+		country:    *countryPtr,
+		address:    *addressPtr,
+		latitude:   *latitudePtr,
+		longitude:  *longitudePtr,
+		elevation:  *elevationPtr,
+		timezone:   *timezonePtr,
+		provider:   *providerPtr,
+		cacheDir:   *cacheDirPtr,
+		database:   *databasePtr,
+		apiURL:     *apiURLPtr,
+		apiTimeout: *apiTimeoutPtr,
+		apiRetries: *apiRetriesPtr,
+		method:     *methodPtr,
+		fajrAngle:  *fajrAnglePtr,
+		ishaAngle:  *ishaAnglePtr,
+		school:     *schoolPtr,
+		midnight:   *midnightPtr,
+		highLat:    *highLatPtr,
+		offset:     *offsetPtr,
+		hijriAdj:   *hijriAdjPtr,
+		reminders:  *remindersPtr,
+		iqamah:     *iqamahPtr,
+		iqamahCSV:  *iqamahCSVPtr,
+		jumuah:     *jumuahPtr,
+		jumuahTime: *jumuahTimePtr,
+		suppress:   *suppressPtr,
+		ramadan:    *ramadanPtr,
+		suhoor:     *suhoorPtr,
+		tahajjud:   *tahajjudPtr,
+		modes:      *modesPtr,
+		volumes:    *volumesPtr,
+		quietHours: *quietHoursPtr,
+		quietMode:  *quietModePtr,
+		quietVol:   *quietVolPtr,
+		fadeIn:     *fadeInPtr,
+		fadeOut:    *fadeOutPtr,
+		playTime:   *playTimePtr,
+		year:       *yearPtr,
+		month:      time.Month(*monthPtr),
+		output:     *outputPtr,
+		command:    *commandPtr,
+		audioDir:   *audioDirPtr,
+		port:       *portPtr,
+		grace:      *gracePtr,
 	}
 
 	log.Printf(
-		"Flags - city: %s, country: %s, offsets: %s, year: %d, month: %d, output: %s, port: %d",
+		"Flags - city: %s, country: %s, address: %s, latitude: %f, longitude: %f, elevation: %f, timezone: %s, provider: %s, cache-dir: %s, database: %s, api-url: %s, api-timeout: %s, api-retries: %d, method: %s, fajr-angle: %g, isha-angle: %g, school: %s, midnight: %s, high-latitude: %s, offsets: %s, reminders: %s, iqamah: %s, iqamah-table: %s, jumuah: %t, jumuah-time: %s, jumuah-suppress-dhuhr: %t, ramadan: %s, suhoor-lead-time: %d, tahajjud: %s, playback-modes: %s, volumes: %s, quiet-hours: %s, quiet-mode: %s, quiet-volume: %d, fade-in: %s, fade-out: %s, playback-timeout: %s, hijri-adjustment: %d, year: %d, month: %d, output: %s, command: %s, audio-dir: %s, port: %d, shutdown-grace: %s",
 		cliFlags.city,
 		cliFlags.country,
+		cliFlags.address,
+		cliFlags.latitude,
+		cliFlags.longitude,
+		cliFlags.elevation,
+		cliFlags.timezone,
+		cliFlags.provider,
+		cliFlags.cacheDir,
+		cliFlags.database,
+		cliFlags.apiURL,
+		cliFlags.apiTimeout,
+		cliFlags.apiRetries,
+		cliFlags.method,
+		cliFlags.fajrAngle,
+		cliFlags.ishaAngle,
+		cliFlags.school,
+		cliFlags.midnight,
+		cliFlags.highLat,
 		cliFlags.offset,
+		cliFlags.reminders,
+		cliFlags.iqamah,
+		cliFlags.iqamahCSV,
+		cliFlags.jumuah,
+		cliFlags.jumuahTime,
+		cliFlags.suppress,
+		cliFlags.ramadan,
+		cliFlags.suhoor,
+		cliFlags.tahajjud,
+		cliFlags.modes,
+		cliFlags.volumes,
+		cliFlags.quietHours,
+		cliFlags.quietMode,
+		cliFlags.quietVol,
+		cliFlags.fadeIn,
+		cliFlags.fadeOut,
+		cliFlags.playTime,
+		cliFlags.hijriAdj,
 		cliFlags.year,
 		cliFlags.month,
 		cliFlags.output,
+		cliFlags.command,
+		cliFlags.audioDir,
 		cliFlags.port,
+		cliFlags.grace,
 	)
 
-	player, err := prayer.GetPlayer(prayer.Output(cliFlags.output))
+	player, err := prayer.GetPlayer(prayer.Output(cliFlags.output), cliFlags.command)
+	if err != nil {
+		log.Fatalln(err)
+	}
+	audioLibrary, err := prayer.NewAudioLibrary(cliFlags.audioDir)
+	if err != nil {
+		log.Fatalln(err)
+	}
+
+	location := prayer.Location{
+		City:      cliFlags.city,
+		Country:   cliFlags.country,
+		Address:   cliFlags.address,
+		Latitude:  cliFlags.latitude,
+		Longitude: cliFlags.longitude,
+		Elevation: cliFlags.elevation,
+		Timezone:  cliFlags.timezone,
+	}
+	calculation, err := getCalculationParams(cliFlags)
+	if err != nil {
+		log.Fatalln(err)
+	}
+	reminders, err := prayer.ParseReminders(cliFlags.reminders)
+	if err != nil {
+		log.Fatalln(err)
+	}
+	iqamah, err := getIqamah(cliFlags)
+	if err != nil {
+		log.Fatalln(err)
+	}
+	var jumuah prayer.Jumuah
+	if cliFlags.jumuah {
+		if jumuah, err = prayer.ParseJumuah(cliFlags.jumuahTime, cliFlags.suppress); err != nil {
+			log.Fatalln(err)
+		}
+	}
+	ramadanMode, err := prayer.ParseRamadanMode(cliFlags.ramadan)
+	if err != nil {
+		log.Fatalln(err)
+	}
+	if cliFlags.suhoor < 0 {
+		log.Fatalf("invalid suhoor-lead-time %d; lead time must not be negative", cliFlags.suhoor)
+	}
+	tahajjud, err := prayer.ParseTahajjudMode(cliFlags.tahajjud)
+	if err != nil {
+		log.Fatalln(err)
+	}
+	playback, err := getPlaybackPolicy(cliFlags)
 	if err != nil {
 		log.Fatalln(err)
 	}
+	settings := prayer.Settings{
+		Location:        location,
+		Calculation:     calculation,
+		Offsets:         cliFlags.offset,
+		HijriAdjustment: cliFlags.hijriAdj,
+		Reminders:       reminders,
+		Iqamah:          iqamah,
+		Jumuah:          jumuah,
+		Ramadan:         prayer.Ramadan{Mode: ramadanMode, SuhoorLeadTime: cliFlags.suhoor},
+		Tahajjud:        tahajjud,
+		Playback:        playback,
+	}
+
+	client := aladhan.NewClient()
+	client.BaseURL = cliFlags.apiURL
+	client.HTTPClient.Timeout = cliFlags.apiTimeout
+	client.Retry.MaxRetries = cliFlags.apiRetries
 
-	prayerDatabase := prayer.NewPrayerDatabase()
-	adhanService := prayer.NewService(player, prayerDatabase)
-	go adhanService.InitialisePrayeralarm(cliFlags.year, cliFlags.month, cliFlags.city, cliFlags.country, cliFlags.offset)
+	calendarProvider, err := prayer.GetCalendarProvider(prayer.Provider(cliFlags.provider), settings, client, cliFlags.cacheDir)
+	if err != nil {
+		log.Fatalln(err)
+	}
 
-	server := server.NewServer(adhanService)
-	server.Run(cliFlags.port)
+	prayerDatabase, err := prayer.GetPrayerDatabase(cliFlags.database)
+	if err != nil {
+		log.Fatalln(err)
+	}
+	adhanService := prayer.NewService(audioLibrary.Wrap(player), prayerDatabase, calendarProvider, settings)
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go adhanService.InitialisePrayeralarm(ctx, cliFlags.year, cliFlags.month)
+
+	server := server.NewServer(adhanService, audioLibrary)
+	serverErr := make(chan error, 1)
+	go func() { serverErr <- server.Run(cliFlags.port) }()
+
+	signals := make(chan os.Signal, 1)
+	signal.Notify(signals, syscall.SIGINT, syscall.SIGTERM)
+	select {
+	case sig := <-signals:
+		log.Printf("received %s signal, shutting down...", sig)
+	case err := <-serverErr:
+		log.Printf("server error, shutting down: %s", err)
+	}
+
+	// Stop scheduling adhans and serving requests, then let in-flight playback finish within the grace period
+	cancel()
+	shutdownCtx, cancelShutdown := context.WithTimeout(context.Background(), serverShutdownTimeout)
+	defer cancelShutdown()
+	if err := server.Shutdown(shutdownCtx); err != nil {
+		log.Printf("error shutting down server: %s", err)
+	}
+
+	graceCtx, cancelGrace := context.WithTimeout(context.Background(), cliFlags.grace)
+	defer cancelGrace()
+	if err := adhanService.Shutdown(graceCtx); err != nil {
+		log.Printf("error shutting down prayeralarm service: %s", err)
+	}
+	log.Println("prayeralarm stopped")
+}
+
+// getCalculationParams returns the calculation parameters from the method, school, midnight and high-latitude flags
+func getCalculationParams(cliFlags cliFlags) (praytime.Params, error) {
+	var method praytime.Method
+	if strings.EqualFold(cliFlags.method, "Custom") {
+		method = praytime.Custom(cliFlags.fajrAngle, cliFlags.ishaAngle)
+	} else {
+		var err error
+		if method, err = praytime.MethodByName(cliFlags.method); err != nil {
+			return praytime.Params{}, err
+		}
+	}
+	params := praytime.DefaultParams(method)
+
+	school, err := praytime.ParseAsrSchool(cliFlags.school)
+	if err != nil {
+		return praytime.Params{}, err
+	}
+	params.School = school
+
+	highLat, err := praytime.ParseHighLatitudeRule(cliFlags.highLat)
+	if err != nil {
+		return praytime.Params{}, err
+	}
+	params.HighLatitude = highLat
+
+	if cliFlags.midnight != "" {
+		midnight, err := praytime.ParseMidnightMode(cliFlags.midnight)
+		if err != nil {
+			return praytime.Params{}, err
+		}
+		params.Midnight = midnight
+	}
+
+	return params, nil
+}
+
+// getIqamah returns the iqamah rules and (optional) table from the iqamah and iqamah-table flags
+func getIqamah(cliFlags cliFlags) (prayer.Iqamah, error) {
+	rules, err := prayer.ParseIqamahRules(cliFlags.iqamah)
+	if err != nil {
+		return prayer.Iqamah{}, err
+	}
+	iqamah := prayer.Iqamah{Rules: rules}
+
+	if cliFlags.iqamahCSV != "" {
+		if iqamah.Table, err = prayer.LoadIqamahTable(cliFlags.iqamahCSV); err != nil {
+			return prayer.Iqamah{}, err
+		}
+	}
+	return iqamah, nil
+}
+
+// getPlaybackPolicy returns the playback policy from the playback-modes, volumes, quiet hours, fade and playback-timeout flags
+func getPlaybackPolicy(cliFlags cliFlags) (prayer.PlaybackPolicy, error) {
+	modes, err := prayer.ParsePlaybackModes(cliFlags.modes)
+	if err != nil {
+		return prayer.PlaybackPolicy{}, err
+	}
+	volumes, err := prayer.ParseVolumes(cliFlags.volumes)
+	if err != nil {
+		return prayer.PlaybackPolicy{}, err
+	}
+	quietHours, err := prayer.ParseQuietHours(cliFlags.quietHours, cliFlags.quietMode, cliFlags.quietVol)
+	if err != nil {
+		return prayer.PlaybackPolicy{}, err
+	}
+	return prayer.PlaybackPolicy{Modes: modes, Volumes: volumes, QuietHours: quietHours, FadeIn: cliFlags.fadeIn, FadeOut: cliFlags.fadeOut, Timeout: cliFlags.playTime}, nil
 }
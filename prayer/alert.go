@@ -0,0 +1,54 @@
+package prayer
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/zees-dev/prayeralarm/aladhan"
+)
+
+type AlertType string
+
+const (
+	REMINDER AlertType = "reminder"
+	IQAMAH   AlertType = "iqamah"
+	SUHOOR   AlertType = "suhoor"
+	IFTAR    AlertType = "iftar"
+)
+
+// Alert is an audio cue of a prayer other than its adhan (i.e. a reminder prior to the adhan, the iqamah,
+// or the suhoor and iftar alerts of fajr and maghrib during Ramadan);
+// identified by its ID, which is stable across restarts
+type Alert struct {
+	ID   string    `json:"id"`
+	Type AlertType `json:"type"`
+	Time time.Time `json:"time"`
+	Play bool      `json:"play"`
+}
+
+// alertID identifies the alert of the prayer; i.e. `2021-01-01-fajr-reminder`
+func alertID(p Prayer, alertType AlertType) string {
+	return fmt.Sprintf("%s-%s", p.ID, alertType)
+}
+
+// Reminders holds the lead times (in mins) of reminders prior to the 5 daily adhans; 0 for no reminder
+type Reminders map[aladhan.Adhan]int
+
+// ParseReminders parses the comma separated reminder lead times (in mins) for the 5 daily adhans
+func ParseReminders(reminders string) (Reminders, error) {
+	leadTimes, err := parseAdhanInts(reminders)
+	if err != nil {
+		return nil, fmt.Errorf(`invalid reminders "%s"; err=%w`, reminders, err)
+	}
+
+	parsed := make(Reminders)
+	for _, adhan := range obligatoryAdhans {
+		if leadTimes[adhan] < 0 {
+			return nil, fmt.Errorf(`invalid reminders "%s"; lead times must not be negative`, reminders)
+		}
+		if leadTimes[adhan] > 0 {
+			parsed[adhan] = leadTimes[adhan]
+		}
+	}
+	return parsed, nil
+}
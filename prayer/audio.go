@@ -1,12 +1,16 @@
 package prayer
 
 import (
+	"context"
+	"encoding/binary"
+	"errors"
 	"fmt"
 	"io"
-	"log"
+	"math"
 	"os"
-	"os/exec"
-	"strings"
+	"path/filepath"
+	"sync/atomic"
+	"time"
 
 	"github.com/hajimehoshi/go-mp3"
 	"github.com/hajimehoshi/oto"
@@ -19,84 +23,136 @@ const (
 	DEFAULT Output = "stdout"
 	OMX     Output = "omx"
 	NATIVE  Output = "native"
+	COMMAND Output = "command"
 )
 
-func GetPlayer(output Output) (Player, error) {
+// GetPlayer returns the player of the output; the `COMMAND` output runs the player command preset or template
+// (see `NewCommandPlayer`)
+func GetPlayer(output Output, command string) (Player, error) {
 	switch output {
 	case DEFAULT:
 		return NewStdOutPlayer(), nil
 	case OMX:
-		return NewOmxPlayer(), nil
+		return NewCommandPlayer("omxplayer")
 	case NATIVE:
 		return NewMp3Player(), nil
+	case COMMAND:
+		return NewCommandPlayer(command)
 	default:
 		return nil, fmt.Errorf("undefined output device '%s'", output)
 	}
 }
 
+// Player plays the audio of the cue until it finishes or the context is done, in which case the context
+// error is returned; players holding resources while playing (i.e. a running process) implement io.Closer
+// to stop in-flight playback
 type Player interface {
-	Play(adhan aladhan.Adhan) error
+	Play(ctx context.Context, cue Cue) error
 }
 
-// Default stdout for testing purposes
-// TODO replicate similar functionality to http defaultservemux
-type stdOut struct{}
+// Cue identifies the audio to be played; the adhan, or an alert (i.e. reminder) of the adhan.
+// Mode, Volume (in percent) and the fade durations are set by the playback policy; empty (or 0) for full playback.
+// File is set by the audio library; empty for the default audio file of the cue.
+type Cue struct {
+	Adhan   aladhan.Adhan `json:"adhan"`
+	Alert   AlertType     `json:"alert,omitempty"`
+	Mode    PlaybackMode  `json:"mode,omitempty"`
+	Volume  int           `json:"volume,omitempty"`
+	FadeIn  time.Duration `json:"fadeIn,omitempty"`
+	FadeOut time.Duration `json:"fadeOut,omitempty"`
+	File    string        `json:"file,omitempty"`
+}
 
-func NewStdOutPlayer() stdOut {
-	return stdOut{}
+func (c Cue) String() string {
+	var name string
+	switch {
+	case c.Alert != "":
+		name = fmt.Sprintf("%s %s", c.Adhan, c.Alert)
+	case !c.Adhan.IsPrayer():
+		name = fmt.Sprintf("%s alarm", c.Adhan)
+	default:
+		name = fmt.Sprintf("%s adhan", c.Adhan)
+	}
+	if c.Mode != "" && c.Mode != FULL {
+		name = fmt.Sprintf("%s (%s)", name, c.Mode)
+	}
+	if volume := c.volume(); volume < maxVolume {
+		name = fmt.Sprintf("%s at %d%% volume", name, volume)
+	}
+	return name
 }
 
-func (so stdOut) Play(adhan aladhan.Adhan) error {
-	fmt.Println(adhan)
-	return nil
+// volume returns the playback volume (in percent) of the cue
+func (c Cue) volume() int {
+	if c.Volume <= 0 || c.Volume > maxVolume {
+		return maxVolume
+	}
+	return c.Volume
 }
 
-// omxplayer binary (must be present in OS)
-type omxPlayer struct{}
+// short reports whether playback of the cue is limited to `shortPlaybackDuration`; notifications are short, so that
+// the adhan played in place of a missing notification track (see `audioKeys`) is not played in full
+func (c Cue) short() bool {
+	return c.Mode == SHORT || c.Mode == NOTIFY
+}
 
-func NewOmxPlayer() omxPlayer {
-	return omxPlayer{}
+// priority orders the cue among cues due at the same time (see `Event.Priority`); adhans are played before
+// alerts, so that the alerts of the adhan time (i.e. the iftar alert) are played once the adhan finishes
+func (c Cue) priority() int {
+	if c.Alert != "" {
+		return 1
+	}
+	return 0
 }
 
-// Play plays an audio via omxplayer cli tool
-func (op omxPlayer) Play(adhan aladhan.Adhan) error {
-	var filename string
-	switch adhan {
-	case aladhan.Fajr:
-		filename = "mp3/adhan-fajr.mp3"
-	default:
-		filename = "mp3/adhan-turkish.mp3"
+// audioFile returns the audio file of the cue; the file set by the audio library, or the default audio file
+// (skipping default files which do not exist)
+func audioFile(cue Cue) string {
+	if cue.File != "" {
+		return cue.File
 	}
-	// fajrAdhan = 'omxplayer -o local --vol 1000 mp3/adhan-fajr.mp3 > /dev/null 2>&1'
-	// otherAdhan = 'omxplayer -o local --vol 1000 mp3/adhan-turkish.mp3 > /dev/null 2>&1'
-	commandStr := strings.Split(fmt.Sprintf("omxplayer -o local --vol 1000 %s > /dev/null 2>&1", filename), " ")
+	return resolveAudio(defaultAudioDir, defaultAssignments, cue, func(track string) bool {
+		_, err := os.Stat(filepath.Join(defaultAudioDir, track))
+		return err == nil
+	})
+}
 
-	log.Println(fmt.Sprintf("executing command: %v", commandStr))
+// ErrPlaybackStopped is returned when in-flight playback is stopped by closing the player
+var ErrPlaybackStopped = errors.New("playback stopped")
 
-	_, err := exec.Command(commandStr[0], commandStr[1:]...).Output()
-	if err != nil {
-		return err
-	}
+// Default stdout for testing purposes
+// TODO replicate similar functionality to http defaultservemux
+type stdOut struct{}
 
-	return nil
+func NewStdOutPlayer() stdOut {
+	return stdOut{}
+}
+
+func (so stdOut) Play(ctx context.Context, cue Cue) error {
+	fmt.Println(cue)
+	return ctx.Err()
+}
+
+// omxVolume converts the playback volume (in percent) to the omxplayer volume (in millibels);
+// full volume is played at 1000 mB
+func omxVolume(volume int) int {
+	return 1000 + int(math.Round(2000*math.Log10(float64(volume)/maxVolume)))
 }
 
 // mp3Player is primarily used to implement interface output mp3 to audio output device
-type mp3Player struct{}
+type mp3Player struct {
+	closed int32
+}
 
-func NewMp3Player() mp3Player {
-	return mp3Player{}
+func NewMp3Player() *mp3Player {
+	return &mp3Player{}
 }
 
-// Play reads the mp3 adhan file ) and outputs mp3 to audio device using `oto` and `go-mp3`
-func (mp mp3Player) Play(adhan aladhan.Adhan) error {
-	var filename string
-	switch adhan {
-	case aladhan.Fajr:
-		filename = "mp3/adhan-fajr.mp3"
-	default:
-		filename = "mp3/adhan-turkish.mp3"
-	}
+// Play reads the mp3 file of the cue and outputs mp3 to audio device using `oto` and `go-mp3`,
+// until the context is done; short cues (see `Cue.short`) are limited to `shortPlaybackDuration` and samples are
+// scaled to the cue volume, fading in and out over the cue fade durations
+func (mp *mp3Player) Play(ctx context.Context, cue Cue) error {
+	filename := audioFile(cue)
 
 	adhanF, err := os.Open(filename)
 	if err != nil {
@@ -118,9 +174,107 @@ func (mp mp3Player) Play(adhan aladhan.Adhan) error {
 	player := c.NewPlayer()
 	defer player.Close()
 
+	frames := decoder.Length() / mp3FrameSize
+	if cue.short() {
+		if shortFrames := int64(shortPlaybackDuration.Seconds()) * int64(decoder.SampleRate()); frames < 0 || frames > shortFrames {
+			frames = shortFrames
+		}
+	}
+
+	var reader io.Reader = io.LimitReader(decoder, frames*mp3FrameSize)
+	if frames < 0 {
+		// Unknown length; the whole audio is played without fading out
+		reader = decoder
+	}
+	if cue.volume() < maxVolume || cue.FadeIn > 0 || cue.FadeOut > 0 {
+		reader = &volumeReader{
+			reader:  reader,
+			volume:  cue.volume(),
+			fadeIn:  durationFrames(cue.FadeIn, decoder.SampleRate()),
+			fadeOut: durationFrames(cue.FadeOut, decoder.SampleRate()),
+			frames:  frames,
+		}
+	}
+
 	fmt.Printf("playing bytes: %d[bytes]\n", decoder.Length())
-	if _, err := io.Copy(player, decoder); err != nil {
+	if _, err := io.Copy(player, stoppableReader{ctx: ctx, reader: reader, stopped: &mp.closed}); err != nil {
 		return err
 	}
 	return nil
 }
+
+// Close stops in-flight playback; the remaining audio is not output to the audio device
+func (mp *mp3Player) Close() error {
+	atomic.StoreInt32(&mp.closed, 1)
+	return nil
+}
+
+// mp3FrameSize is the number of bytes of a decoded (2 channel, 16-bit) PCM frame
+const mp3FrameSize = 4
+
+// durationFrames returns the number of PCM frames played over d at the sample rate
+func durationFrames(d time.Duration, sampleRate int) int64 {
+	return int64(d.Seconds() * float64(sampleRate))
+}
+
+// volumeReader scales the 16-bit little-endian PCM samples read from reader by volume (in percent);
+// the volume fades in over the first fadeIn frames and out over the last fadeOut frames of the
+// audio length (in frames), which is not faded out if unknown (negative)
+type volumeReader struct {
+	reader  io.Reader
+	volume  int
+	fadeIn  int64
+	fadeOut int64
+	frames  int64
+	offset  int64 // bytes read
+}
+
+func (vr *volumeReader) Read(p []byte) (int, error) {
+	if len(p) < 2 {
+		return 0, io.ErrShortBuffer
+	}
+	n, err := io.ReadAtLeast(vr.reader, p[:len(p)&^1], 2)
+	if n%2 != 0 {
+		// Complete the trailing sample so that samples are not split across reads
+		m, fullErr := io.ReadFull(vr.reader, p[n:n+1])
+		n += m
+		if err == nil {
+			err = fullErr
+		}
+	}
+	for i := 0; i+1 < n; i += 2 {
+		sample := float64(int16(binary.LittleEndian.Uint16(p[i:])))
+		binary.LittleEndian.PutUint16(p[i:], uint16(int16(sample*vr.gain((vr.offset+int64(i))/mp3FrameSize))))
+	}
+	vr.offset += int64(n)
+	return n, err
+}
+
+// gain returns the sample scale of the frame, by volume and its position within the fades
+func (vr *volumeReader) gain(frame int64) float64 {
+	gain := float64(vr.volume) / maxVolume
+	if frame < vr.fadeIn {
+		gain *= float64(frame) / float64(vr.fadeIn)
+	}
+	if remaining := vr.frames - frame; vr.frames >= 0 && remaining < vr.fadeOut {
+		gain *= float64(remaining) / float64(vr.fadeOut)
+	}
+	return gain
+}
+
+// stoppableReader reads from reader until stopped is set or the context is done
+type stoppableReader struct {
+	ctx     context.Context
+	reader  io.Reader
+	stopped *int32
+}
+
+func (sr stoppableReader) Read(p []byte) (int, error) {
+	if atomic.LoadInt32(sr.stopped) != 0 {
+		return 0, ErrPlaybackStopped
+	}
+	if err := sr.ctx.Err(); err != nil {
+		return 0, err
+	}
+	return sr.reader.Read(p)
+}
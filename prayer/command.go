@@ -0,0 +1,261 @@
+package prayer
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"math"
+	"os/exec"
+	"strings"
+	"sync"
+	"text/template"
+)
+
+// CommandPresets are the built-in command templates of external audio players, by preset name
+var CommandPresets = map[string]string{
+	"omxplayer": "omxplayer -o local --vol {{.Millibels}} {{.File}}",
+	"mpv":       "mpv --no-video --really-quiet --volume={{.Volume}} {{if .Fade}}--af={{.Fade}}{{end}} {{.File}}",
+	"mpg123":    "mpg123 -q --scale {{.Scale}} {{.File}}",
+	"ffplay":    "ffplay -nodisp -autoexit -loglevel error -volume {{.Volume}} {{if .Fade}}-af{{end}} {{.Fade}} {{.File}}",
+	"cvlc":      "cvlc --play-and-exit --quiet --gain {{.Gain}} {{.File}}",
+}
+
+// maxStderrSize is the maximum number of trailing stderr bytes of a player command kept for logging
+const maxStderrSize = 4 << 10
+
+// CommandArgs are the fields of a player command template
+type CommandArgs struct {
+	File      string  // audio file of the cue
+	Volume    int     // volume (in percent)
+	Millibels int     // volume in millibels, where full volume is 1000 mB (omxplayer)
+	Scale     int     // volume as a sample scale factor, where full volume is 32768 (mpg123)
+	Gain      string  // volume as a gain, where full volume is 1.00 (vlc)
+	FadeIn    float64 // fade in duration (in seconds); 0 for no fade
+	FadeOut   float64 // fade out duration (in seconds); 0 for no fade, or when the audio length is unknown
+	Fade      string  // ffmpeg audio filter of the fades; i.e. `afade=t=in:d=5,afade=t=out:st=25:d=5` (mpv, ffplay)
+}
+
+// newCommandArgs returns the command template fields of the cue
+func newCommandArgs(cue Cue) CommandArgs {
+	volume := cue.volume()
+	args := CommandArgs{
+		File:      audioFile(cue),
+		Volume:    volume,
+		Millibels: omxVolume(volume),
+		Scale:     32768 * volume / maxVolume,
+		Gain:      fmt.Sprintf("%.2f", float64(volume)/maxVolume),
+		FadeIn:    cue.FadeIn.Seconds(),
+	}
+
+	var fades []string
+	if args.FadeIn > 0 {
+		fades = append(fades, fmt.Sprintf("afade=t=in:d=%g", args.FadeIn))
+	}
+	if cue.FadeOut > 0 {
+		// The fade out starts relative to the end of playback, so the audio length is required
+		end := readTrack(args.File).Duration
+		if short := shortPlaybackDuration.Seconds(); cue.short() && (end <= 0 || end > short) {
+			end = short
+		}
+		if end > 0 {
+			args.FadeOut = math.Min(cue.FadeOut.Seconds(), end)
+			fades = append(fades, fmt.Sprintf("afade=t=out:st=%g:d=%g", end-args.FadeOut, args.FadeOut))
+		}
+	}
+	args.Fade = strings.Join(fades, ",")
+	return args
+}
+
+// CommandError is returned when a player command exits unsuccessfully
+type CommandError struct {
+	Command  string
+	ExitCode int
+	Stderr   string
+}
+
+func (e *CommandError) Error() string {
+	if e.Stderr == "" {
+		return fmt.Sprintf("command %s exited with code %d", e.Command, e.ExitCode)
+	}
+	return fmt.Sprintf("command %s exited with code %d: %s", e.Command, e.ExitCode, e.Stderr)
+}
+
+// commandPlayer plays cues by running an external audio player command (must be present in OS)
+type commandPlayer struct {
+	args   []*template.Template
+	mutex  sync.Mutex
+	cmd    *exec.Cmd
+	closed bool
+}
+
+// NewCommandPlayer returns a player running the command of the preset name (see `CommandPresets`), or the
+// command template; i.e. `mpv --volume={{.Volume}} {{.File}}`. The template is split into arguments by
+// whitespace (outside of template actions) before the fields (see `CommandArgs`) are applied, so that fields
+// are passed as single arguments without shell interpretation; arguments which are empty once applied are omitted.
+func NewCommandPlayer(command string) (*commandPlayer, error) {
+	if preset, ok := CommandPresets[command]; ok {
+		command = preset
+	}
+
+	fields := splitCommand(command)
+	if len(fields) == 0 {
+		return nil, errors.New("empty player command")
+	}
+	args := make([]*template.Template, len(fields))
+	for i, field := range fields {
+		tmpl, err := template.New(fmt.Sprintf("arg%d", i)).Parse(field)
+		if err != nil {
+			return nil, fmt.Errorf(`invalid player command "%s"; err=%w`, command, err)
+		}
+		args[i] = tmpl
+	}
+
+	// Validate the command fields against a cue
+	if _, err := (&commandPlayer{args: args}).command(Cue{}); err != nil {
+		return nil, fmt.Errorf(`invalid player command "%s"; err=%w`, command, err)
+	}
+	return &commandPlayer{args: args}, nil
+}
+
+// splitCommand splits the command template into arguments by whitespace outside of template actions (`{{...}}`)
+func splitCommand(command string) []string {
+	var fields []string
+	var field strings.Builder
+	depth := 0
+	for i := 0; i < len(command); i++ {
+		switch {
+		case strings.HasPrefix(command[i:], "{{"):
+			depth++
+			field.WriteString("{{")
+			i++
+		case strings.HasPrefix(command[i:], "}}") && depth > 0:
+			depth--
+			field.WriteString("}}")
+			i++
+		case depth == 0 && (command[i] == ' ' || command[i] == '\t' || command[i] == '\n'):
+			if field.Len() > 0 {
+				fields = append(fields, field.String())
+				field.Reset()
+			}
+		default:
+			field.WriteByte(command[i])
+		}
+	}
+	if field.Len() > 0 {
+		fields = append(fields, field.String())
+	}
+	return fields
+}
+
+// command returns the command arguments of the cue
+func (cp *commandPlayer) command(cue Cue) ([]string, error) {
+	data := newCommandArgs(cue)
+	command := make([]string, 0, len(cp.args))
+	for _, tmpl := range cp.args {
+		var arg strings.Builder
+		if err := tmpl.Execute(&arg, data); err != nil {
+			return nil, err
+		}
+		if arg.Len() > 0 {
+			command = append(command, arg.String())
+		}
+	}
+	if len(command) == 0 {
+		return nil, errors.New("empty player command")
+	}
+	return command, nil
+}
+
+// Play plays the cue by running the player command; the process is killed when the context is done.
+// Short cues (see `Cue.short`) are stopped after `shortPlaybackDuration`. A `CommandError` is returned with the exit code
+// and stderr of an unsuccessful command.
+func (cp *commandPlayer) Play(ctx context.Context, cue Cue) error {
+	command, err := cp.command(cue)
+	if err != nil {
+		return err
+	}
+	log.Printf("executing command: %q", command)
+
+	playCtx := ctx
+	if cue.short() {
+		var cancel context.CancelFunc
+		playCtx, cancel = context.WithTimeout(ctx, shortPlaybackDuration)
+		defer cancel()
+	}
+
+	stderr := &tailBuffer{size: maxStderrSize}
+	cmd := exec.CommandContext(playCtx, command[0], command[1:]...)
+	cmd.Stderr = stderr
+
+	cp.mutex.Lock()
+	if cp.closed {
+		cp.mutex.Unlock()
+		return ErrPlaybackStopped
+	}
+	if err := cmd.Start(); err != nil {
+		cp.mutex.Unlock()
+		return err
+	}
+	cp.cmd = cmd
+	cp.mutex.Unlock()
+
+	err = cmd.Wait()
+
+	cp.mutex.Lock()
+	defer cp.mutex.Unlock()
+	cp.cmd = nil
+	if cp.closed {
+		return ErrPlaybackStopped
+	}
+	if ctx.Err() != nil {
+		return ctx.Err()
+	}
+	if playCtx.Err() != nil {
+		// Short playback finished
+		return nil
+	}
+	var exitErr *exec.ExitError
+	if errors.As(err, &exitErr) {
+		return &CommandError{Command: command[0], ExitCode: exitErr.ExitCode(), Stderr: strings.TrimSpace(stderr.String())}
+	}
+	if output := strings.TrimSpace(stderr.String()); err == nil && output != "" {
+		log.Printf("%s: %s", command[0], output)
+	}
+	return err
+}
+
+// Close stops in-flight playback by killing the player process
+func (cp *commandPlayer) Close() error {
+	cp.mutex.Lock()
+	defer cp.mutex.Unlock()
+	cp.closed = true
+	if cp.cmd == nil {
+		return nil
+	}
+	return cp.cmd.Process.Kill()
+}
+
+// tailBuffer keeps the last size bytes written to it
+type tailBuffer struct {
+	mutex sync.Mutex
+	buf   bytes.Buffer
+	size  int
+}
+
+func (tb *tailBuffer) Write(p []byte) (int, error) {
+	tb.mutex.Lock()
+	defer tb.mutex.Unlock()
+	tb.buf.Write(p)
+	if overflow := tb.buf.Len() - tb.size; overflow > 0 {
+		tb.buf.Next(overflow)
+	}
+	return len(p), nil
+}
+
+func (tb *tailBuffer) String() string {
+	tb.mutex.Lock()
+	defer tb.mutex.Unlock()
+	return tb.buf.String()
+}
@@ -0,0 +1,155 @@
+package prayer
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/zees-dev/prayeralarm/aladhan"
+)
+
+// newTestCommand writes an executable shell script of the body, returning its path
+func newTestCommand(t *testing.T, body string) string {
+	path := filepath.Join(t.TempDir(), "player.sh")
+	if err := ioutil.WriteFile(path, []byte("#!/bin/sh\n"+body+"\n"), 0755); err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	return path
+}
+
+func TestCommandPlayerArgs(t *testing.T) {
+	tests := []struct {
+		name    string
+		command string
+		cue     Cue
+		want    []string
+	}{
+		{
+			"test omxplayer preset",
+			"omxplayer",
+			Cue{Adhan: aladhan.Fajr, Volume: 10, File: "mp3/adhan-fajr.mp3"},
+			[]string{"omxplayer", "-o", "local", "--vol", "-1000", "mp3/adhan-fajr.mp3"},
+		},
+		{
+			"test mpv preset with fade in",
+			"mpv",
+			Cue{Adhan: aladhan.Isha, Volume: 50, FadeIn: 2500 * time.Millisecond, File: "mp3/adhan.mp3"},
+			[]string{"mpv", "--no-video", "--really-quiet", "--volume=50", "--af=afade=t=in:d=2.5", "mp3/adhan.mp3"},
+		},
+		{
+			"test ffplay preset with fade in",
+			"ffplay",
+			Cue{Adhan: aladhan.Isha, Volume: 50, FadeIn: 2 * time.Second, File: "mp3/adhan.mp3"},
+			[]string{"ffplay", "-nodisp", "-autoexit", "-loglevel", "error", "-volume", "50", "-af", "afade=t=in:d=2", "mp3/adhan.mp3"},
+		},
+		{
+			"test mpv preset with fade out of short playback",
+			"mpv",
+			Cue{Adhan: aladhan.Isha, Mode: SHORT, FadeIn: time.Second, FadeOut: 5 * time.Second, File: "mp3/adhan.mp3"},
+			[]string{"mpv", "--no-video", "--really-quiet", "--volume=100", "--af=afade=t=in:d=1,afade=t=out:st=25:d=5", "mp3/adhan.mp3"},
+		},
+		{
+			"test fade out is omitted when the audio length is unknown",
+			"mpv",
+			Cue{Adhan: aladhan.Isha, FadeOut: 5 * time.Second, File: "mp3/adhan.mp3"},
+			[]string{"mpv", "--no-video", "--really-quiet", "--volume=100", "mp3/adhan.mp3"},
+		},
+		{
+			"test empty arguments are omitted",
+			"mpv",
+			Cue{Adhan: aladhan.Isha, File: "mp3/adhan.mp3"},
+			[]string{"mpv", "--no-video", "--really-quiet", "--volume=100", "mp3/adhan.mp3"},
+		},
+		{
+			"test fields are single arguments",
+			"paplay  {{.File}}",
+			Cue{Adhan: aladhan.Isha, File: "my adhans/adhan; rm -rf.mp3"},
+			[]string{"paplay", "my adhans/adhan; rm -rf.mp3"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			player, err := NewCommandPlayer(tt.command)
+			if err != nil {
+				t.Fatalf("unexpected error: %s", err)
+			}
+			got, err := player.command(tt.cue)
+			if err != nil {
+				t.Fatalf("unexpected error: %s", err)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("want %q, got %q", tt.want, got)
+			}
+		})
+	}
+
+	t.Run("test fade out starts before the end of the audio", func(t *testing.T) {
+		file := "../mp3/test.mp3"
+		length := readTrack(file).Duration
+		if length <= 1 {
+			t.Fatalf("want audio longer than 1s, got %f", length)
+		}
+		args := newCommandArgs(Cue{Adhan: aladhan.Isha, FadeOut: time.Second, File: file})
+		if want := fmt.Sprintf("afade=t=out:st=%g:d=1", length-1); args.Fade != want {
+			t.Errorf("want %s, got %s", want, args.Fade)
+		}
+	})
+
+	t.Run("test invalid commands", func(t *testing.T) {
+		for _, command := range []string{"", "mpv {{.File", "mpv {{.Speed}} {{.File}}"} {
+			if _, err := NewCommandPlayer(command); err == nil {
+				t.Errorf("want error for %s, got nil", command)
+			}
+		}
+	})
+}
+
+func TestCommandPlayer(t *testing.T) {
+	cue := Cue{Adhan: aladhan.Fajr, File: "adhan.mp3"}
+
+	t.Run("test command is run with the cue file", func(t *testing.T) {
+		out := filepath.Join(t.TempDir(), "played")
+		player, err := NewCommandPlayer(newTestCommand(t, `echo "$1" > `+out) + " {{.File}}")
+		if err != nil {
+			t.Fatalf("unexpected error: %s", err)
+		}
+		if err := player.Play(context.Background(), cue); err != nil {
+			t.Fatalf("unexpected error: %s", err)
+		}
+		if got, _ := ioutil.ReadFile(out); strings.TrimSpace(string(got)) != cue.File {
+			t.Errorf("want %s, got %s", cue.File, got)
+		}
+	})
+
+	t.Run("test exit code and stderr are reported", func(t *testing.T) {
+		player, err := NewCommandPlayer(newTestCommand(t, "echo 'no audio device' >&2; exit 3") + " {{.File}}")
+		if err != nil {
+			t.Fatalf("unexpected error: %s", err)
+		}
+		var cmdErr *CommandError
+		if err := player.Play(context.Background(), cue); !errors.As(err, &cmdErr) {
+			t.Fatalf("want command error, got %v", err)
+		}
+		if cmdErr.ExitCode != 3 || cmdErr.Stderr != "no audio device" {
+			t.Errorf("want exit code 3 and stderr 'no audio device', got %+v", cmdErr)
+		}
+	})
+
+	t.Run("test command is killed when context is done", func(t *testing.T) {
+		player, err := NewCommandPlayer(newTestCommand(t, "exec sleep 10") + " {{.File}}")
+		if err != nil {
+			t.Fatalf("unexpected error: %s", err)
+		}
+		ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+		defer cancel()
+		if err := player.Play(ctx, cue); !errors.Is(err, context.DeadlineExceeded) {
+			t.Errorf("want %s, got %v", context.DeadlineExceeded, err)
+		}
+	})
+}
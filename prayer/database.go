@@ -1,18 +1,37 @@
 package prayer
 
 import (
+	"encoding/json"
+	"errors"
 	"fmt"
+	"io/ioutil"
+	"log"
+	"os"
+	"path/filepath"
+	"strings"
 	"sync"
 	"time"
+
+	"github.com/zees-dev/prayeralarm/internal/atomicfile"
 )
 
 type PrayerDatabase interface {
 	Timings() []DailyPrayerTimings
-	SetTimings(prayerTimings []DailyPrayerTimings)
-	SetPrayerTime(dailyPrayerIndex int, prayerIndex int, prayerTime Prayer)
+	SetTimings(prayerTimings []DailyPrayerTimings) error
+	SetPrayer(prayer Prayer) error
+	GetPrayer(id string) (Prayer, error)
 	GetPrayerByTime(prayerTime time.Time) (Prayer, error)
 }
 
+// GetPrayerDatabase returns the prayer database persisted to the file at path;
+// or an in-memory database if path is empty
+func GetPrayerDatabase(path string) (PrayerDatabase, error) {
+	if path == "" {
+		return NewPrayerDatabase(), nil
+	}
+	return NewFileDatabase(path)
+}
+
 type database struct {
 	mutex         sync.RWMutex
 	prayerTimings []DailyPrayerTimings
@@ -22,10 +41,11 @@ func NewPrayerDatabase() *database {
 	return &database{prayerTimings: []DailyPrayerTimings{}}
 }
 
-func (db *database) SetTimings(prayerTimings []DailyPrayerTimings) {
+func (db *database) SetTimings(prayerTimings []DailyPrayerTimings) error {
 	db.mutex.Lock()
 	defer db.mutex.Unlock()
 	db.prayerTimings = prayerTimings
+	return nil
 }
 
 func (db *database) Timings() []DailyPrayerTimings {
@@ -34,10 +54,37 @@ func (db *database) Timings() []DailyPrayerTimings {
 	return db.prayerTimings
 }
 
-func (db *database) SetPrayerTime(dailyPrayerIndex int, prayerIndex int, prayerTime Prayer) {
+// SetPrayer replaces the prayer (or non-adhan timing) of the same ID
+func (db *database) SetPrayer(prayer Prayer) error {
 	db.mutex.Lock()
 	defer db.mutex.Unlock()
-	db.prayerTimings[dailyPrayerIndex].Prayers[prayerIndex] = prayerTime
+	for _, dpt := range db.prayerTimings {
+		for _, prayers := range [][]Prayer{dpt.Prayers, dpt.Extras} {
+			for i := range prayers {
+				if prayers[i].ID == prayer.ID {
+					prayers[i] = prayer
+					return nil
+				}
+			}
+		}
+	}
+	return fmt.Errorf("unable to find prayer; id=%s", prayer.ID)
+}
+
+// GetPrayer returns the prayer (or non-adhan timing) of the ID
+func (db *database) GetPrayer(id string) (Prayer, error) {
+	db.mutex.RLock()
+	defer db.mutex.RUnlock()
+	for _, dpt := range db.prayerTimings {
+		for _, prayers := range [][]Prayer{dpt.Prayers, dpt.Extras} {
+			for _, prayer := range prayers {
+				if prayer.ID == id {
+					return prayer, nil
+				}
+			}
+		}
+	}
+	return Prayer{}, fmt.Errorf("unable to find prayer; id=%s", id)
 }
 
 func (db *database) GetPrayerByTime(prayerTime time.Time) (Prayer, error) {
@@ -52,3 +99,227 @@ func (db *database) GetPrayerByTime(prayerTime time.Time) (Prayer, error) {
 	}
 	return Prayer{}, fmt.Errorf("unable to find prayer by time; t=%s", prayerTime)
 }
+
+// migration upgrades a database document by a single schema version
+type migration func(doc map[string]json.RawMessage) error
+
+// migrations[i] upgrades database documents of version i+1 to version i+2;
+// a migration is appended for each change of the schema
+var migrations = []migration{
+	migratePrayerIDs,
+}
+
+// databaseVersion returns the latest schema version of the database file
+func databaseVersion() int {
+	return len(migrations) + 1
+}
+
+// databaseDocument is the schema of the database file
+type databaseDocument struct {
+	Version int                     `json:"version"`
+	Prayers map[string]storedPrayer `json:"prayers"`
+}
+
+// storedPrayer holds the persisted settings of a prayer (or alert)
+type storedPrayer struct {
+	Play bool `json:"play"`
+}
+
+// fileDatabase is a prayer database which persists prayer settings (i.e. whether the adhan is played)
+// to a JSON file, keyed by prayer (or alert) ID; settings are applied to the prayer timings when set
+type fileDatabase struct {
+	*database
+	mutex   sync.Mutex
+	path    string
+	prayers map[string]storedPrayer
+}
+
+// errUnsupportedVersion is returned when loading a database document of an unknown (i.e. newer) schema version
+var errUnsupportedVersion = errors.New("unsupported schema version")
+
+// NewFileDatabase returns a prayer database persisted to the file at path, loading the file if it exists;
+// a corrupt file is moved aside and the database starts empty
+func NewFileDatabase(path string) (*fileDatabase, error) {
+	db := &fileDatabase{database: NewPrayerDatabase(), path: path, prayers: make(map[string]storedPrayer)}
+
+	data, err := ioutil.ReadFile(path)
+	if os.IsNotExist(err) {
+		return db, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	doc, err := migrateDocument(data)
+	if errors.Is(err, errUnsupportedVersion) {
+		return nil, fmt.Errorf("error loading prayer database %s: %w", path, err)
+	}
+	if err != nil {
+		// A corrupt (i.e. truncated) database must not stop the alarm; it is kept aside for inspection
+		corruptPath := fmt.Sprintf("%s.corrupt-%s", path, time.Now().Format("20060102150405"))
+		log.Printf("error loading prayer database %s: %s; moving it to %s and starting empty", path, err, corruptPath)
+		if err := os.Rename(path, corruptPath); err != nil {
+			return nil, fmt.Errorf("error moving corrupt prayer database %s: %w", path, err)
+		}
+		return db, nil
+	}
+	if doc.Prayers != nil {
+		db.prayers = doc.Prayers
+	}
+	return db, nil
+}
+
+// migrateDocument decodes the database file, upgrading it to the current schema version
+func migrateDocument(data []byte) (databaseDocument, error) {
+	var raw map[string]json.RawMessage
+	if err := json.Unmarshal(data, &raw); err != nil {
+		return databaseDocument{}, err
+	}
+
+	var version int
+	if err := json.Unmarshal(raw["version"], &version); err != nil {
+		return databaseDocument{}, fmt.Errorf("invalid schema version: %w", err)
+	}
+	if version < 1 || version > databaseVersion() {
+		return databaseDocument{}, fmt.Errorf("%w %d; latest supported version is %d", errUnsupportedVersion, version, databaseVersion())
+	}
+
+	for ; version < databaseVersion(); version++ {
+		if err := migrations[version-1](raw); err != nil {
+			return databaseDocument{}, fmt.Errorf("error migrating schema version %d: %w", version, err)
+		}
+	}
+	raw["version"] = json.RawMessage(fmt.Sprint(databaseVersion()))
+
+	migrated, err := json.Marshal(raw)
+	if err != nil {
+		return databaseDocument{}, err
+	}
+	var doc databaseDocument
+	if err := json.Unmarshal(migrated, &doc); err != nil {
+		return databaseDocument{}, err
+	}
+	return doc, nil
+}
+
+// migratePrayerIDs re-keys prayers from `2021-01-01/Fajr` to their prayer ID; i.e. `2021-01-01-fajr`
+func migratePrayerIDs(doc map[string]json.RawMessage) error {
+	var prayers map[string]json.RawMessage
+	if err := json.Unmarshal(doc["prayers"], &prayers); err != nil {
+		return err
+	}
+
+	migrated := make(map[string]json.RawMessage, len(prayers))
+	for key, prayer := range prayers {
+		parts := strings.SplitN(key, "/", 2)
+		if len(parts) != 2 {
+			return fmt.Errorf("invalid prayer key %s", key)
+		}
+		migrated[fmt.Sprintf("%s-%s", parts[0], strings.ToLower(parts[1]))] = prayer
+	}
+
+	data, err := json.Marshal(migrated)
+	if err != nil {
+		return err
+	}
+	doc["prayers"] = data
+	return nil
+}
+
+// SetTimings applies the persisted prayer settings to the prayer timings; settings of prayers prior
+// to the prayer timings are discarded
+func (db *fileDatabase) SetTimings(prayerTimings []DailyPrayerTimings) error {
+	db.mutex.Lock()
+	defer db.mutex.Unlock()
+
+	firstDate := firstPrayerDate(prayerTimings)
+	for _, dpt := range prayerTimings {
+		for i := range dpt.Prayers {
+			if stored, ok := db.prayers[dpt.Prayers[i].ID]; ok {
+				dpt.Prayers[i].Play = stored.Play
+			}
+			for j, alert := range dpt.Prayers[i].Alerts {
+				if stored, ok := db.prayers[alert.ID]; ok {
+					dpt.Prayers[i].Alerts[j].Play = stored.Play
+				}
+			}
+		}
+		for i := range dpt.Extras {
+			if stored, ok := db.prayers[dpt.Extras[i].ID]; ok {
+				dpt.Extras[i].Play = stored.Play
+			}
+		}
+	}
+	for key := range db.prayers {
+		// Prayer IDs are prefixed by the prayer date, which is ordered lexically
+		if key < firstDate {
+			delete(db.prayers, key)
+		}
+	}
+
+	if err := db.database.SetTimings(prayerTimings); err != nil {
+		return err
+	}
+	return db.save()
+}
+
+// firstPrayerDate returns the earliest date (in the prayer ID date format) of the prayer timings; the dates of
+// the days along with the date prefixes of their prayer and non-adhan timing IDs are considered, as timings
+// of the night (i.e. isha and tahajjud) may be past midnight while keeping the date of their day
+func firstPrayerDate(prayerTimings []DailyPrayerTimings) string {
+	var firstDate string
+	setFirstDate := func(date string) {
+		if firstDate == "" || date < firstDate {
+			firstDate = date
+		}
+	}
+	for _, dpt := range prayerTimings {
+		setFirstDate(dpt.Date.Format(prayerIDDateFormat))
+		for _, prayers := range [][]Prayer{dpt.Prayers, dpt.Extras} {
+			for _, prayer := range prayers {
+				if len(prayer.ID) >= len(prayerIDDateFormat) {
+					setFirstDate(prayer.ID[:len(prayerIDDateFormat)])
+				}
+			}
+		}
+	}
+	return firstDate
+}
+
+// SetPrayer replaces the prayer of the same ID, persisting its settings
+func (db *fileDatabase) SetPrayer(prayer Prayer) error {
+	db.mutex.Lock()
+	defer db.mutex.Unlock()
+
+	if err := db.database.SetPrayer(prayer); err != nil {
+		return err
+	}
+	db.prayers[prayer.ID] = storedPrayer{Play: prayer.Play}
+	for _, alert := range prayer.Alerts {
+		db.prayers[alert.ID] = storedPrayer{Play: alert.Play}
+	}
+	return db.save()
+}
+
+// Close persists the prayer settings
+func (db *fileDatabase) Close() error {
+	db.mutex.Lock()
+	defer db.mutex.Unlock()
+	return db.save()
+}
+
+// save persists the prayer settings; the file is replaced atomically
+func (db *fileDatabase) save() error {
+	if dir := filepath.Dir(db.path); dir != "" {
+		if err := os.MkdirAll(dir, 0755); err != nil {
+			return err
+		}
+	}
+
+	data, err := json.MarshalIndent(databaseDocument{Version: databaseVersion(), Prayers: db.prayers}, "", "  ")
+	if err != nil {
+		return err
+	}
+
+	return atomicfile.WriteFile(db.path, data, 0644)
+}
@@ -0,0 +1,205 @@
+package prayer
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/zees-dev/prayeralarm/aladhan"
+)
+
+// testTimings returns the prayer timings of a day with the adhans set to play
+func testTimings(date time.Time) []DailyPrayerTimings {
+	return []DailyPrayerTimings{{
+		Date: date,
+		Prayers: []Prayer{
+			{ID: prayerID(aladhan.Fajr, date), Play: true, Type: aladhan.Fajr, Time: date.Add(5 * time.Hour), Index: 0},
+			{ID: prayerID(aladhan.Dhuhr, date), Play: true, Type: aladhan.Dhuhr, Time: date.Add(13 * time.Hour), Index: 1},
+		},
+	}}
+}
+
+func TestFileDatabase(t *testing.T) {
+	date := time.Date(2021, 1, 1, 0, 0, 0, 0, time.UTC)
+
+	t.Run("test prayer settings survive reopening", func(t *testing.T) {
+		path := filepath.Join(t.TempDir(), "prayers.json")
+		db, err := NewFileDatabase(path)
+		if err != nil {
+			t.Fatalf("unexpected error: %s", err)
+		}
+		if err := db.SetTimings(testTimings(date)); err != nil {
+			t.Fatalf("unexpected error: %s", err)
+		}
+		fajr := db.Timings()[0].Prayers[0]
+		fajr.Play = false
+		if err := db.SetPrayer(fajr); err != nil {
+			t.Fatalf("unexpected error: %s", err)
+		}
+
+		reopened, err := NewFileDatabase(path)
+		if err != nil {
+			t.Fatalf("unexpected error: %s", err)
+		}
+		// Positional indexes may differ between restarts; settings are matched by prayer ID
+		timings := testTimings(date)
+		timings[0].Prayers[0].Index, timings[0].Prayers[1].Index = 7, 8
+		if err := reopened.SetTimings(timings); err != nil {
+			t.Fatalf("unexpected error: %s", err)
+		}
+
+		got := reopened.Timings()[0].Prayers
+		if got[0].Play || !got[1].Play {
+			t.Errorf("want muted fajr and played dhuhr, got %+v", got)
+		}
+	})
+
+	t.Run("test settings of past prayers are discarded", func(t *testing.T) {
+		path := filepath.Join(t.TempDir(), "prayers.json")
+		db, err := NewFileDatabase(path)
+		if err != nil {
+			t.Fatalf("unexpected error: %s", err)
+		}
+		db.SetTimings(testTimings(date))
+		if err := db.SetPrayer(db.Timings()[0].Prayers[0]); err != nil {
+			t.Fatalf("unexpected error: %s", err)
+		}
+		if err := db.SetTimings(testTimings(date.AddDate(0, 0, 1))); err != nil {
+			t.Fatalf("unexpected error: %s", err)
+		}
+
+		if len(db.prayers) != 0 {
+			t.Errorf("want %d stored prayers, got %d", 0, len(db.prayers))
+		}
+	})
+
+	t.Run("test settings of timings past midnight survive reopening", func(t *testing.T) {
+		path := filepath.Join(t.TempDir(), "prayers.json")
+		// The trailing night of the day, where isha and tahajjud are past midnight
+		night := func() []DailyPrayerTimings {
+			return []DailyPrayerTimings{{
+				Date:    date,
+				Prayers: []Prayer{{ID: prayerID(aladhan.Isha, date), Play: true, Type: aladhan.Isha, Time: date.Add(24*time.Hour + 30*time.Minute)}},
+				Extras:  []Prayer{{ID: prayerID(aladhan.Tahajjud, date), Play: true, Type: aladhan.Tahajjud, Time: date.Add(27 * time.Hour)}},
+			}}
+		}
+		db, err := NewFileDatabase(path)
+		if err != nil {
+			t.Fatalf("unexpected error: %s", err)
+		}
+		if err := db.SetTimings(night()); err != nil {
+			t.Fatalf("unexpected error: %s", err)
+		}
+		tahajjud := db.Timings()[0].Extras[0]
+		tahajjud.Play = false
+		if err := db.SetPrayer(tahajjud); err != nil {
+			t.Fatalf("unexpected error: %s", err)
+		}
+
+		// Settings are applied before past settings are discarded, so the setting must survive repeated reloads
+		for i := 0; i < 2; i++ {
+			reopened, err := NewFileDatabase(path)
+			if err != nil {
+				t.Fatalf("unexpected error: %s", err)
+			}
+			if err := reopened.SetTimings(night()); err != nil {
+				t.Fatalf("unexpected error: %s", err)
+			}
+			if got := reopened.Timings()[0].Extras[0]; got.Play {
+				t.Errorf("want muted tahajjud after reload %d, got %+v", i+1, got)
+			}
+		}
+	})
+
+	t.Run("test setting unknown prayer", func(t *testing.T) {
+		db, err := NewFileDatabase(filepath.Join(t.TempDir(), "prayers.json"))
+		if err != nil {
+			t.Fatalf("unexpected error: %s", err)
+		}
+		db.SetTimings(testTimings(date))
+
+		if err := db.SetPrayer(Prayer{ID: prayerID(aladhan.Isha, date), Type: aladhan.Isha, Time: date}); err == nil {
+			t.Error("want error, got nil")
+		}
+	})
+
+	t.Run("test documents are migrated to the latest schema version", func(t *testing.T) {
+		defer func(m []migration) { migrations = m }(migrations)
+		// Hypothetical version which replaces `muted` with `play`
+		migrations = append(migrations, func(doc map[string]json.RawMessage) error {
+			var prayers map[string]struct {
+				Muted bool `json:"muted"`
+			}
+			if err := json.Unmarshal(doc["prayers"], &prayers); err != nil {
+				return err
+			}
+			migrated := make(map[string]storedPrayer)
+			for key, p := range prayers {
+				migrated[key] = storedPrayer{Play: !p.Muted}
+			}
+			data, err := json.Marshal(migrated)
+			doc["prayers"] = data
+			return err
+		})
+
+		path := filepath.Join(t.TempDir(), "prayers.json")
+		ioutil.WriteFile(path, []byte(`{"version": 1, "prayers": {"2021-01-01/Fajr": {"muted": true}}}`), 0644)
+		db, err := NewFileDatabase(path)
+		if err != nil {
+			t.Fatalf("unexpected error: %s", err)
+		}
+
+		if got, ok := db.prayers["2021-01-01-fajr"]; !ok || got.Play {
+			t.Errorf("want muted fajr, got %+v", db.prayers)
+		}
+	})
+
+	t.Run("test prayers are re-keyed by prayer ID", func(t *testing.T) {
+		path := filepath.Join(t.TempDir(), "prayers.json")
+		ioutil.WriteFile(path, []byte(`{"version": 1, "prayers": {"2021-01-01/Fajr": {"play": false}}}`), 0644)
+		db, err := NewFileDatabase(path)
+		if err != nil {
+			t.Fatalf("unexpected error: %s", err)
+		}
+
+		want := prayerID(aladhan.Fajr, date)
+		if _, ok := db.prayers[want]; !ok || len(db.prayers) != 1 {
+			t.Errorf("want %s, got %+v", want, db.prayers)
+		}
+	})
+
+	t.Run("test documents of newer schema versions are rejected", func(t *testing.T) {
+		path := filepath.Join(t.TempDir(), "prayers.json")
+		ioutil.WriteFile(path, []byte(`{"version": 99, "prayers": {}}`), 0644)
+
+		_, err := NewFileDatabase(path)
+		if err == nil || !strings.Contains(err.Error(), "unsupported schema version 99") {
+			t.Errorf("want unsupported schema version error, got %v", err)
+		}
+	})
+	t.Run("test corrupt documents are moved aside", func(t *testing.T) {
+		dir := t.TempDir()
+		path := filepath.Join(dir, "prayers.json")
+		ioutil.WriteFile(path, []byte(`{"version": 2, "prayers": {"2021-01-01-fajr": {"pl`), 0644)
+
+		db, err := NewFileDatabase(path)
+		if err != nil {
+			t.Fatalf("unexpected error: %s", err)
+		}
+		if len(db.prayers) != 0 {
+			t.Errorf("want empty database, got %+v", db.prayers)
+		}
+		if corrupt, _ := filepath.Glob(path + ".corrupt-*"); len(corrupt) != 1 {
+			t.Errorf("want corrupt database to be kept aside, got %v", corrupt)
+		}
+		if err := db.SetTimings(testTimings(date)); err != nil {
+			t.Fatalf("unexpected error: %s", err)
+		}
+		if _, err := NewFileDatabase(path); err != nil {
+			t.Errorf("unexpected error: %s", err)
+		}
+	})
+}
@@ -0,0 +1,160 @@
+package prayer
+
+import (
+	"encoding/csv"
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/zees-dev/prayeralarm/aladhan"
+)
+
+// iqamahClockFormat is the format of fixed iqamah clock times
+const iqamahClockFormat = "15:04"
+
+// IqamahRule determines the iqamah time of an adhan; either an offset after the adhan or a fixed clock time
+type IqamahRule struct {
+	Offset int    `json:"offset,omitempty"` // mins after the adhan
+	Time   string `json:"time,omitempty"`   // fixed clock time; i.e. "13:30"
+}
+
+// IqamahTable holds the iqamah clock times of adhans by date (i.e. published by the mosque);
+// keyed by date (`2006-01-02`) and adhan
+type IqamahTable map[string]map[aladhan.Adhan]string
+
+// Iqamah holds the iqamah rules of the 5 daily adhans; times of the table take precedence over the rules
+type Iqamah struct {
+	Rules map[aladhan.Adhan]IqamahRule `json:"rules"`
+	Table IqamahTable                  `json:"-"`
+}
+
+// iqamahTime returns the iqamah time of the prayer of the calendar day (see `CalendarDay.Date`), if any.
+// Table times and fixed clock times are of the calendar day; those of prayers past midnight of the
+// calendar day (i.e. isha at high latitudes) fall on the following day when prior to the adhan.
+func (iq Iqamah) iqamahTime(p Prayer, date time.Time) (time.Time, bool) {
+	var clock string
+	if times, ok := iq.Table[date.Format(prayerIDDateFormat)]; ok && times[p.Type] != "" {
+		clock = times[p.Type]
+	} else if rule, ok := iq.Rules[p.Type]; ok {
+		if rule.Time == "" {
+			return p.Time.Add(time.Duration(rule.Offset) * time.Minute), rule.Offset > 0
+		}
+		clock = rule.Time
+	} else {
+		return time.Time{}, false
+	}
+
+	t, err := time.Parse(iqamahClockFormat, clock)
+	if err != nil {
+		return time.Time{}, false
+	}
+	year, month, day := date.Date()
+	iqamahTime := time.Date(year, month, day, t.Hour(), t.Minute(), 0, 0, p.Time.Location())
+	if !iqamahTime.After(p.Time) && p.Time.After(time.Date(year, month, day+1, 0, 0, 0, 0, p.Time.Location())) {
+		iqamahTime = iqamahTime.AddDate(0, 0, 1)
+	}
+	// Fixed iqamah times prior to the adhan (i.e. outdated by the seasonal adhan time) are ignored
+	return iqamahTime, iqamahTime.After(p.Time)
+}
+
+// ParseIqamahRules parses the comma separated iqamah rules for the 5 daily adhans;
+// `+N` (or `N`) for N mins after the adhan, `HH:MM` for a fixed clock time, or empty (or `0`) for no iqamah
+func ParseIqamahRules(rules string) (map[aladhan.Adhan]IqamahRule, error) {
+	ruleSlice := strings.Split(rules, ",")
+	if len(ruleSlice) != 5 {
+		return nil, fmt.Errorf(`invalid iqamah rules "%s"; 5 comma separated rules are required`, rules)
+	}
+
+	parsed := make(map[aladhan.Adhan]IqamahRule)
+	for i, adhan := range obligatoryAdhans {
+		rule := strings.TrimSpace(ruleSlice[i])
+		if rule == "" || rule == "0" {
+			continue
+		}
+		if strings.Contains(rule, ":") {
+			if _, err := time.Parse(iqamahClockFormat, rule); err != nil {
+				return nil, fmt.Errorf(`invalid iqamah time "%s" for %s; HH:MM is required`, rule, adhan)
+			}
+			parsed[adhan] = IqamahRule{Time: rule}
+			continue
+		}
+		offset, err := strconv.Atoi(strings.TrimPrefix(rule, "+"))
+		if err != nil || offset < 0 {
+			return nil, fmt.Errorf(`invalid iqamah offset "%s" for %s; +N mins is required`, rule, adhan)
+		}
+		parsed[adhan] = IqamahRule{Offset: offset}
+	}
+	return parsed, nil
+}
+
+// LoadIqamahTable reads the iqamah table from a CSV file with a header row;
+// i.e. `date,fajr,dhuhr,asr,maghrib,isha` followed by rows of `2021-01-01,05:00,13:30,17:45,,22:00`
+func LoadIqamahTable(path string) (IqamahTable, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	table, err := readIqamahTable(f)
+	if err != nil {
+		return nil, fmt.Errorf("error reading iqamah table %s: %w", path, err)
+	}
+	return table, nil
+}
+
+func readIqamahTable(r io.Reader) (IqamahTable, error) {
+	reader := csv.NewReader(r)
+	reader.TrimLeadingSpace = true
+
+	header, err := reader.Read()
+	if err != nil {
+		return nil, err
+	}
+	columns := make(map[int]aladhan.Adhan)
+	dateColumn := -1
+	for i, name := range header {
+		if strings.EqualFold(name, "date") {
+			dateColumn = i
+			continue
+		}
+		for _, adhan := range obligatoryAdhans {
+			if strings.EqualFold(name, string(adhan)) {
+				columns[i] = adhan
+			}
+		}
+	}
+	if dateColumn < 0 {
+		return nil, fmt.Errorf("missing date column")
+	}
+
+	table := make(IqamahTable)
+	for {
+		record, err := reader.Read()
+		if err == io.EOF {
+			return table, nil
+		}
+		if err != nil {
+			return nil, err
+		}
+
+		date := record[dateColumn]
+		if _, err := time.Parse(prayerIDDateFormat, date); err != nil {
+			return nil, fmt.Errorf(`invalid date "%s"; YYYY-MM-DD is required`, date)
+		}
+		times := make(map[aladhan.Adhan]string)
+		for i, adhan := range columns {
+			if record[i] == "" {
+				continue
+			}
+			if _, err := time.Parse(iqamahClockFormat, record[i]); err != nil {
+				return nil, fmt.Errorf(`invalid iqamah time "%s" for %s on %s; HH:MM is required`, record[i], adhan, date)
+			}
+			times[adhan] = record[i]
+		}
+		table[date] = times
+	}
+}
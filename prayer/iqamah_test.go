@@ -0,0 +1,129 @@
+package prayer
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/zees-dev/prayeralarm/aladhan"
+)
+
+func TestParseIqamahRules(t *testing.T) {
+	t.Run("test offsets and fixed times are parsed", func(t *testing.T) {
+		got, err := ParseIqamahRules("+20,13:30,10,0,")
+		if err != nil {
+			t.Fatalf("unexpected error: %s", err)
+		}
+
+		want := map[aladhan.Adhan]IqamahRule{
+			aladhan.Fajr:  {Offset: 20},
+			aladhan.Dhuhr: {Time: "13:30"},
+			aladhan.Asr:   {Offset: 10},
+		}
+		if len(got) != len(want) {
+			t.Fatalf("want %+v, got %+v", want, got)
+		}
+		for adhan, rule := range want {
+			if got[adhan] != rule {
+				t.Errorf("%s: want %+v, got %+v", adhan, rule, got[adhan])
+			}
+		}
+	})
+
+	t.Run("test invalid rules", func(t *testing.T) {
+		for _, rules := range []string{"+20,0,0,0", "25:00,0,0,0,0", "-5,0,0,0,0", "soon,0,0,0,0"} {
+			if _, err := ParseIqamahRules(rules); err == nil {
+				t.Errorf("%s: want error, got nil", rules)
+			}
+		}
+	})
+}
+
+func TestLoadIqamahTable(t *testing.T) {
+	t.Run("test iqamah times are read by date", func(t *testing.T) {
+		table, err := LoadIqamahTable("testdata/iqamah.csv")
+		if err != nil {
+			t.Fatalf("unexpected error: %s", err)
+		}
+
+		if len(table) != 2 {
+			t.Fatalf("want %d days, got %d", 2, len(table))
+		}
+		day := table["2021-01-01"]
+		if day[aladhan.Dhuhr] != "13:30" {
+			t.Errorf("want %s, got %s", "13:30", day[aladhan.Dhuhr])
+		}
+		if _, ok := day[aladhan.Maghrib]; ok {
+			t.Errorf("want no maghrib iqamah, got %s", day[aladhan.Maghrib])
+		}
+	})
+
+	t.Run("test invalid tables", func(t *testing.T) {
+		for _, csv := range []string{
+			"fajr,dhuhr\n05:00,13:30\n",
+			"date,fajr\n01/01/2021,05:00\n",
+			"date,fajr\n2021-01-01,5am\n",
+		} {
+			if _, err := readIqamahTable(strings.NewReader(csv)); err == nil {
+				t.Errorf("%q: want error, got nil", csv)
+			}
+		}
+	})
+}
+
+func TestIqamahTime(t *testing.T) {
+	l, _ := time.LoadLocation("Pacific/Auckland")
+	date := time.Date(2021, 1, 1, 0, 0, 0, 0, l)
+	dhuhr := Prayer{Type: aladhan.Dhuhr, Time: time.Date(2021, 1, 1, 13, 23, 0, 0, l)}
+
+	t.Run("test offset after adhan", func(t *testing.T) {
+		iqamah := Iqamah{Rules: map[aladhan.Adhan]IqamahRule{aladhan.Dhuhr: {Offset: 15}}}
+
+		want := time.Date(2021, 1, 1, 13, 38, 0, 0, l)
+		if got, ok := iqamah.iqamahTime(dhuhr, date); !ok || !got.Equal(want) {
+			t.Errorf("want %s, got %s", want, got)
+		}
+	})
+
+	t.Run("test fixed time in the adhan timezone", func(t *testing.T) {
+		iqamah := Iqamah{Rules: map[aladhan.Adhan]IqamahRule{aladhan.Dhuhr: {Time: "13:45"}}}
+
+		want := time.Date(2021, 1, 1, 13, 45, 0, 0, l)
+		if got, ok := iqamah.iqamahTime(dhuhr, date); !ok || !got.Equal(want) {
+			t.Errorf("want %s, got %s", want, got)
+		}
+	})
+
+	t.Run("test table takes precedence over rules", func(t *testing.T) {
+		iqamah := Iqamah{
+			Rules: map[aladhan.Adhan]IqamahRule{aladhan.Dhuhr: {Offset: 15}},
+			Table: IqamahTable{"2021-01-01": {aladhan.Dhuhr: "13:30"}},
+		}
+
+		want := time.Date(2021, 1, 1, 13, 30, 0, 0, l)
+		if got, ok := iqamah.iqamahTime(dhuhr, date); !ok || !got.Equal(want) {
+			t.Errorf("want %s, got %s", want, got)
+		}
+	})
+
+	t.Run("test fixed time prior to adhan is ignored", func(t *testing.T) {
+		iqamah := Iqamah{Rules: map[aladhan.Adhan]IqamahRule{aladhan.Dhuhr: {Time: "13:00"}}}
+
+		if got, ok := iqamah.iqamahTime(dhuhr, date); ok {
+			t.Errorf("want no iqamah, got %s", got)
+		}
+	})
+
+	t.Run("test table and fixed times of isha past midnight are of the calendar day", func(t *testing.T) {
+		isha := Prayer{Type: aladhan.Isha, Time: time.Date(2021, 1, 2, 0, 20, 0, 0, l)}
+		for name, iqamah := range map[string]Iqamah{
+			"table": {Table: IqamahTable{"2021-01-01": {aladhan.Isha: "00:40"}, "2021-01-02": {aladhan.Isha: "00:50"}}},
+			"rule":  {Rules: map[aladhan.Adhan]IqamahRule{aladhan.Isha: {Time: "00:40"}}},
+		} {
+			want := time.Date(2021, 1, 2, 0, 40, 0, 0, l)
+			if got, ok := iqamah.iqamahTime(isha, date); !ok || !got.Equal(want) {
+				t.Errorf("%s: want %s, got %s", name, want, got)
+			}
+		}
+	})
+}
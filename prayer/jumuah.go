@@ -0,0 +1,53 @@
+package prayer
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/zees-dev/prayeralarm/aladhan"
+)
+
+// Jumuah holds the settings of the Friday prayer, which is played in the Dhuhr slot on Fridays;
+// at a fixed khutbah time (or the Dhuhr time if unset), optionally suppressing the regular Dhuhr adhan
+type Jumuah struct {
+	Enabled       bool   `json:"enabled"`
+	Time          string `json:"time,omitempty"` // fixed khutbah clock time; i.e. "13:15"
+	SuppressDhuhr bool   `json:"suppressDhuhr"`
+}
+
+// ParseJumuah returns the enabled jumuah settings with the khutbah clock time (`HH:MM`), or the Dhuhr time if empty
+func ParseJumuah(khutbahTime string, suppressDhuhr bool) (Jumuah, error) {
+	if khutbahTime != "" {
+		if _, err := time.Parse(iqamahClockFormat, khutbahTime); err != nil {
+			return Jumuah{}, fmt.Errorf(`invalid jumuah time "%s"; HH:MM is required`, khutbahTime)
+		}
+	}
+	return Jumuah{Enabled: true, Time: khutbahTime, SuppressDhuhr: suppressDhuhr}, nil
+}
+
+// jumuahPrayer returns the jumuah of the Dhuhr timing, if it is on a Friday
+func (j Jumuah) jumuahPrayer(dhuhrTime time.Time) (Prayer, bool) {
+	if !j.Enabled || dhuhrTime.Weekday() != time.Friday {
+		return Prayer{}, false
+	}
+
+	jumuahTime := dhuhrTime
+	if j.Time != "" {
+		t, err := time.Parse(iqamahClockFormat, j.Time)
+		if err != nil {
+			return Prayer{}, false
+		}
+		year, month, day := dhuhrTime.Date()
+		jumuahTime = time.Date(year, month, day, t.Hour(), t.Minute(), 0, 0, dhuhrTime.Location())
+	}
+	return Prayer{ID: prayerID(aladhan.Jumuah, jumuahTime), Play: true, Type: aladhan.Jumuah, Time: jumuahTime}, true
+}
+
+// settingsAdhan returns the adhan whose settings (i.e. reminders, iqamah and playback) apply to the adhan;
+// Jumuah follows the Dhuhr settings
+func settingsAdhan(adhan aladhan.Adhan) aladhan.Adhan {
+	if adhan == aladhan.Jumuah {
+		return aladhan.Dhuhr
+	}
+	return adhan
+}
@@ -0,0 +1,147 @@
+package prayer
+
+import (
+	"bytes"
+	"context"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/zees-dev/prayeralarm/aladhan"
+)
+
+func TestParseJumuah(t *testing.T) {
+	t.Run("test khutbah time", func(t *testing.T) {
+		got, err := ParseJumuah("13:15", true)
+		if err != nil {
+			t.Fatalf("unexpected error: %s", err)
+		}
+		want := Jumuah{Enabled: true, Time: "13:15", SuppressDhuhr: true}
+		if got != want {
+			t.Errorf("want %+v, got %+v", want, got)
+		}
+	})
+
+	t.Run("test invalid khutbah time", func(t *testing.T) {
+		if _, err := ParseJumuah("1pm", false); err == nil {
+			t.Error("want error, got nil")
+		}
+	})
+}
+
+func TestJumuah(t *testing.T) {
+	// Dhuhr of the Friday and Saturday after next week, so that all timings are upcoming
+	now := time.Now()
+	friday := time.Date(now.Year(), now.Month(), now.Day()+7+int(time.Friday-now.Weekday()+7)%7, 12, 30, 0, 0, time.Local)
+	saturday := friday.AddDate(0, 0, 1)
+	days := []CalendarDay{
+		{Date: friday, Timings: map[aladhan.Adhan]time.Time{aladhan.Dhuhr: friday, aladhan.Asr: friday.Add(3 * time.Hour)}},
+		{Date: saturday, Timings: map[aladhan.Adhan]time.Time{aladhan.Dhuhr: saturday}},
+	}
+
+	prayerTypes := func(prayers []Prayer) []aladhan.Adhan {
+		types := make([]aladhan.Adhan, 0, len(prayers))
+		for _, p := range prayers {
+			types = append(types, p.Type)
+		}
+		return types
+	}
+
+	t.Run("test jumuah is not generated when disabled", func(t *testing.T) {
+		svc := NewService(NewStdOutPlayer(), NewPrayerDatabase(), stubProvider{}, Settings{})
+		got, err := svc.generatePrayers(days)
+		if err != nil {
+			t.Fatalf("unexpected error: %s", err)
+		}
+		for _, p := range got[0].Prayers {
+			if p.Type == aladhan.Jumuah {
+				t.Errorf("want no jumuah, got %+v", p)
+			}
+		}
+	})
+
+	t.Run("test jumuah at khutbah time replaces dhuhr on fridays", func(t *testing.T) {
+		settings := Settings{
+			Jumuah:    Jumuah{Enabled: true, Time: "13:15", SuppressDhuhr: true},
+			Reminders: Reminders{aladhan.Dhuhr: 10},
+		}
+		svc := NewService(NewStdOutPlayer(), NewPrayerDatabase(), stubProvider{}, settings)
+		got, err := svc.generatePrayers(days)
+		if err != nil {
+			t.Fatalf("unexpected error: %s", err)
+		}
+
+		want := []aladhan.Adhan{aladhan.Jumuah, aladhan.Asr}
+		if types := prayerTypes(got[0].Prayers); len(types) != len(want) || types[0] != want[0] || types[1] != want[1] {
+			t.Fatalf("want %v, got %v", want, types)
+		}
+		jumuah := got[0].Prayers[0]
+		wantTime := time.Date(friday.Year(), friday.Month(), friday.Day(), 13, 15, 0, 0, time.Local)
+		if !jumuah.Time.Equal(wantTime) {
+			t.Errorf("want %s, got %s", wantTime, jumuah.Time)
+		}
+		if wantID := prayerID(aladhan.Jumuah, friday); jumuah.ID != wantID {
+			t.Errorf("want %s, got %s", wantID, jumuah.ID)
+		}
+		if len(jumuah.Alerts) != 1 || !jumuah.Alerts[0].Time.Equal(wantTime.Add(-10*time.Minute)) {
+			t.Errorf("want dhuhr reminder 10 mins prior to jumuah, got %+v", jumuah.Alerts)
+		}
+
+		if types := prayerTypes(got[1].Prayers); len(types) != 1 || types[0] != aladhan.Dhuhr {
+			t.Errorf("want [%s], got %v", aladhan.Dhuhr, types)
+		}
+	})
+
+	t.Run("test jumuah at dhuhr time replaces dhuhr", func(t *testing.T) {
+		svc := NewService(NewStdOutPlayer(), NewPrayerDatabase(), stubProvider{}, Settings{Jumuah: Jumuah{Enabled: true}})
+		got, err := svc.generatePrayers(days)
+		if err != nil {
+			t.Fatalf("unexpected error: %s", err)
+		}
+
+		want := []aladhan.Adhan{aladhan.Jumuah, aladhan.Asr}
+		if types := prayerTypes(got[0].Prayers); len(types) != len(want) || types[0] != want[0] || types[1] != want[1] {
+			t.Fatalf("want %v, got %v", want, types)
+		}
+		if jumuah := got[0].Prayers[0]; !jumuah.Time.Equal(friday) {
+			t.Errorf("want %s at %s, got %s", jumuah.Type, friday, jumuah.Time)
+		}
+
+		var buf bytes.Buffer
+		svc.DisplayPrayerTimings(&buf, got)
+		if !strings.Contains(buf.String(), string(aladhan.Jumuah)) {
+			t.Errorf("want %s in table, got %s", aladhan.Jumuah, buf.String())
+		}
+	})
+
+	t.Run("test a single cue is scheduled at the dhuhr time", func(t *testing.T) {
+		provider := stubProvider{days: days}
+		svc := NewService(NewStdOutPlayer(), NewPrayerDatabase(), provider, Settings{Jumuah: Jumuah{Enabled: true}})
+		if err := svc.loadCalendar(context.Background(), friday.Year(), friday.Month()); err != nil {
+			t.Fatalf("unexpected error: %s", err)
+		}
+
+		var got []string
+		for _, event := range svc.scheduler.Events() {
+			if event.Time.Equal(friday) {
+				got = append(got, event.ID)
+			}
+		}
+		if want := adhanEventID(Prayer{ID: prayerID(aladhan.Jumuah, friday)}); len(got) != 1 || got[0] != want {
+			t.Errorf("want [%s], got %v", want, got)
+		}
+	})
+
+	t.Run("test jumuah at khutbah time alongside dhuhr", func(t *testing.T) {
+		svc := NewService(NewStdOutPlayer(), NewPrayerDatabase(), stubProvider{}, Settings{Jumuah: Jumuah{Enabled: true, Time: "13:15"}})
+		got, err := svc.generatePrayers(days)
+		if err != nil {
+			t.Fatalf("unexpected error: %s", err)
+		}
+
+		want := []aladhan.Adhan{aladhan.Dhuhr, aladhan.Jumuah, aladhan.Asr}
+		if types := prayerTypes(got[0].Prayers); len(types) != len(want) || types[0] != want[0] || types[1] != want[1] || types[2] != want[2] {
+			t.Errorf("want %v, got %v", want, types)
+		}
+	})
+}
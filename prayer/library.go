@@ -0,0 +1,418 @@
+package prayer
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"log"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/hajimehoshi/go-mp3"
+	"github.com/zees-dev/prayeralarm/internal/atomicfile"
+)
+
+// defaultAudioDir is the directory of the default audio files
+const defaultAudioDir = "mp3"
+
+// assignmentsFile is the file of the audio library directory to which track assignments are persisted
+const assignmentsFile = "assignments.json"
+
+// AudioKeys are the keys of cues to which audio tracks are assigned; `adhan` is the track of adhans
+// without a track of their own
+var AudioKeys = []string{
+	"fajr", "dhuhr", "jumuah", "asr", "maghrib", "isha", "adhan", "tahajjud",
+	string(REMINDER), string(IQAMAH), string(SUHOOR), string(IFTAR), "notification",
+}
+
+// defaultAssignments are the tracks of the default audio directory assigned to cue keys
+var defaultAssignments = map[string]string{
+	"fajr":           "adhan-fajr.mp3",
+	"adhan":          "adhan-turkish.mp3",
+	"tahajjud":       "tahajjud.mp3",
+	string(REMINDER): "reminder.mp3",
+	string(IQAMAH):   "iqamah.mp3",
+	string(SUHOOR):   "suhoor.mp3",
+	string(IFTAR):    "iftar.mp3",
+	"notification":   "notification.mp3",
+}
+
+// audioKeys returns the keys of the cue, from the most to the least specific; i.e. jumuah, dhuhr, then adhan.
+// Alerts fall back to the notification track, and notifications to the adhan track (played short; see `Cue.short`)
+func audioKeys(cue Cue) []string {
+	switch {
+	case cue.Mode == NOTIFY:
+		return append([]string{"notification"}, audioKeys(Cue{Adhan: cue.Adhan})...)
+	case cue.Alert != "":
+		return []string{string(cue.Alert), "notification"}
+	case settingsAdhan(cue.Adhan) != cue.Adhan:
+		return []string{strings.ToLower(string(cue.Adhan)), strings.ToLower(string(settingsAdhan(cue.Adhan))), "adhan"}
+	default:
+		return []string{strings.ToLower(string(cue.Adhan)), "adhan"}
+	}
+}
+
+// resolveAudio returns the file of the track assigned to the cue within dir; the most specific playable track,
+// falling back to the most specific assigned track if none are playable
+func resolveAudio(dir string, assignments map[string]string, cue Cue, playable func(track string) bool) string {
+	var fallback string
+	for _, key := range audioKeys(cue) {
+		track, ok := assignments[key]
+		if !ok {
+			continue
+		}
+		if playable(track) {
+			return filepath.Join(dir, track)
+		}
+		if fallback == "" {
+			fallback = track
+		}
+	}
+	if fallback == "" {
+		fallback = defaultAssignments["adhan"]
+	}
+	return filepath.Join(dir, fallback)
+}
+
+// MaxTrackSize is the maximum size (in bytes) of tracks added to the audio library
+const MaxTrackSize = 20 << 20
+
+var (
+	// ErrTrackNotFound is returned when the track is not in the audio library
+	ErrTrackNotFound = errors.New("track not found")
+	// ErrTrackExists is returned when a track of the same name is already in the audio library
+	ErrTrackExists = errors.New("track already exists")
+	// ErrTrackAssigned is returned when deleting a track which is assigned to cues
+	ErrTrackAssigned = errors.New("track is assigned")
+	// ErrInvalidTrack is returned when the track name is invalid, or the track is too large or does not decode
+	ErrInvalidTrack = errors.New("invalid track")
+	// ErrUndefinedAudioKey is returned when assigning a track to a key which is not one of `AudioKeys`
+	ErrUndefinedAudioKey = errors.New("undefined audio key")
+)
+
+// Track is an mp3 file of the audio library
+type Track struct {
+	Name     string  `json:"name"`
+	Duration float64 `json:"duration"`        // in seconds
+	Error    string  `json:"error,omitempty"` // reason the track cannot be played; i.e. it does not decode
+}
+
+// AudioLibrary holds the mp3 tracks of a directory and their assignment to cues (by cue key);
+// tracks are validated to decode when the library is loaded
+type AudioLibrary struct {
+	mutex       sync.RWMutex
+	dir         string
+	tracks      map[string]Track
+	assignments map[string]string
+}
+
+// NewAudioLibrary scans the directory for mp3 tracks and loads their persisted assignments (if any),
+// falling back to the default assignments; malformed assignments and assignments to missing or invalid tracks are logged
+func NewAudioLibrary(dir string) (*AudioLibrary, error) {
+	lib := &AudioLibrary{dir: dir, tracks: make(map[string]Track), assignments: make(map[string]string)}
+	for key, track := range defaultAssignments {
+		lib.assignments[key] = track
+	}
+
+	files, err := ioutil.ReadDir(dir)
+	if err != nil {
+		return nil, fmt.Errorf("error scanning audio library %s: %w", dir, err)
+	}
+	for _, file := range files {
+		if file.IsDir() || !strings.EqualFold(filepath.Ext(file.Name()), ".mp3") {
+			continue
+		}
+		lib.tracks[file.Name()] = readTrack(filepath.Join(dir, file.Name()))
+	}
+
+	// Invalid assignments must not stop the alarm; the default assignments are played instead
+	assignmentsPath := filepath.Join(dir, assignmentsFile)
+	data, err := ioutil.ReadFile(assignmentsPath)
+	if err != nil && !os.IsNotExist(err) {
+		log.Printf("warning: error reading audio assignments %s: %s; using default assignments", assignmentsPath, err)
+	}
+	if err == nil {
+		var assignments map[string]string
+		if err := json.Unmarshal(data, &assignments); err != nil {
+			log.Printf("warning: error loading audio assignments %s: %s; using default assignments", assignmentsPath, err)
+			assignments = nil
+		}
+		for key, track := range assignments {
+			lib.assignments[key] = track
+		}
+	}
+
+	for _, key := range AudioKeys {
+		track, ok := lib.assignments[key]
+		if !ok {
+			continue
+		}
+		if err := lib.validate(track); err != nil {
+			log.Printf("warning: %s audio is unplayable: %s", key, err)
+		}
+	}
+	return lib, nil
+}
+
+// readTrack validates the mp3 file and determines its duration. This is a shallow check, so that large libraries are
+// scanned quickly: the frame headers of the whole file are read (to determine its length), but only the first frame
+// is decoded; corrupt audio data past the first frame is reported by the player once the track is played.
+func readTrack(path string) Track {
+	track := Track{Name: filepath.Base(path)}
+
+	f, err := os.Open(path)
+	if err != nil {
+		track.Error = err.Error()
+		return track
+	}
+	defer f.Close()
+
+	decoder, err := mp3.NewDecoder(f)
+	if err != nil {
+		track.Error = err.Error()
+		return track
+	}
+	if _, err := io.CopyN(ioutil.Discard, decoder, mp3FrameSize); err != nil {
+		track.Error = err.Error()
+		return track
+	}
+	track.Duration = float64(decoder.Length()) / float64(decoder.SampleRate()*mp3FrameSize)
+	return track
+}
+
+// validate returns an error if the track is not in the library or does not decode
+func (lib *AudioLibrary) validate(name string) error {
+	track, ok := lib.tracks[name]
+	if !ok {
+		return fmt.Errorf("%w: %s in %s", ErrTrackNotFound, name, lib.dir)
+	}
+	if track.Error != "" {
+		return fmt.Errorf("%w: %s does not decode: %s", ErrInvalidTrack, name, track.Error)
+	}
+	return nil
+}
+
+// validateTrackName returns an error unless the name is an mp3 file name without a directory
+func validateTrackName(name string) error {
+	if name == "" || filepath.Base(name) != name || strings.HasPrefix(name, ".") || !strings.EqualFold(filepath.Ext(name), ".mp3") {
+		return fmt.Errorf("%w: name %s must be an mp3 file name", ErrInvalidTrack, name)
+	}
+	return nil
+}
+
+// Add adds the mp3 track read from r to the library; tracks larger than `MaxTrackSize`,
+// or which do not decode, are rejected
+func (lib *AudioLibrary) Add(name string, r io.Reader) (Track, error) {
+	if err := validateTrackName(name); err != nil {
+		return Track{}, err
+	}
+
+	// The track is written to a temporary file of the library directory, which is renamed once validated
+	tmp, err := ioutil.TempFile(lib.dir, ".upload-*.mp3")
+	if err != nil {
+		return Track{}, err
+	}
+	defer os.Remove(tmp.Name())
+	n, err := io.Copy(tmp, io.LimitReader(r, MaxTrackSize+1))
+	if closeErr := tmp.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		return Track{}, err
+	}
+	if n > MaxTrackSize {
+		return Track{}, fmt.Errorf("%w: %s exceeds %d bytes", ErrInvalidTrack, name, MaxTrackSize)
+	}
+	track := readTrack(tmp.Name())
+	if track.Error != "" {
+		return Track{}, fmt.Errorf("%w: %s does not decode: %s", ErrInvalidTrack, name, track.Error)
+	}
+	track.Name = name
+
+	lib.mutex.Lock()
+	defer lib.mutex.Unlock()
+
+	path := filepath.Join(lib.dir, name)
+	if _, err := os.Stat(path); err == nil {
+		return Track{}, fmt.Errorf("%w: %s", ErrTrackExists, name)
+	}
+	if err := os.Rename(tmp.Name(), path); err != nil {
+		return Track{}, err
+	}
+	lib.tracks[name] = track
+	return track, nil
+}
+
+// Rename renames the track, along with its assignments
+func (lib *AudioLibrary) Rename(name, newName string) error {
+	if err := validateTrackName(newName); err != nil {
+		return err
+	}
+
+	lib.mutex.Lock()
+	defer lib.mutex.Unlock()
+
+	track, ok := lib.tracks[name]
+	if !ok {
+		return fmt.Errorf("%w: %s", ErrTrackNotFound, name)
+	}
+	newPath := filepath.Join(lib.dir, newName)
+	if _, err := os.Stat(newPath); err == nil {
+		return fmt.Errorf("%w: %s", ErrTrackExists, newName)
+	}
+	if err := os.Rename(filepath.Join(lib.dir, name), newPath); err != nil {
+		return err
+	}
+	delete(lib.tracks, name)
+	track.Name = newName
+	lib.tracks[newName] = track
+
+	keys := lib.assignedKeys(name)
+	if len(keys) == 0 {
+		return nil
+	}
+	for _, key := range keys {
+		lib.assignments[key] = newName
+	}
+	return lib.save()
+}
+
+// Delete removes the track from the library; tracks assigned to cues are not deleted
+func (lib *AudioLibrary) Delete(name string) error {
+	lib.mutex.Lock()
+	defer lib.mutex.Unlock()
+
+	if _, ok := lib.tracks[name]; !ok {
+		return fmt.Errorf("%w: %s", ErrTrackNotFound, name)
+	}
+	if keys := lib.assignedKeys(name); len(keys) > 0 {
+		return fmt.Errorf("%w: %s is assigned to %s", ErrTrackAssigned, name, strings.Join(keys, ", "))
+	}
+	if err := os.Remove(filepath.Join(lib.dir, name)); err != nil {
+		return err
+	}
+	delete(lib.tracks, name)
+	return nil
+}
+
+// TrackPath returns the file of the track; i.e. to be previewed
+func (lib *AudioLibrary) TrackPath(name string) (string, error) {
+	lib.mutex.RLock()
+	defer lib.mutex.RUnlock()
+
+	if _, ok := lib.tracks[name]; !ok {
+		return "", fmt.Errorf("%w: %s", ErrTrackNotFound, name)
+	}
+	return filepath.Join(lib.dir, name), nil
+}
+
+// assignedKeys returns the cue keys to which the track is assigned, ordered by key
+func (lib *AudioLibrary) assignedKeys(name string) []string {
+	keys := make([]string, 0)
+	for key, track := range lib.assignments {
+		if track == name {
+			keys = append(keys, key)
+		}
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// Tracks returns the tracks of the library, ordered by name
+func (lib *AudioLibrary) Tracks() []Track {
+	lib.mutex.RLock()
+	defer lib.mutex.RUnlock()
+
+	tracks := make([]Track, 0, len(lib.tracks))
+	for _, track := range lib.tracks {
+		tracks = append(tracks, track)
+	}
+	sort.Slice(tracks, func(i, j int) bool {
+		return tracks[i].Name < tracks[j].Name
+	})
+	return tracks
+}
+
+// Assignments returns the tracks assigned to cue keys
+func (lib *AudioLibrary) Assignments() map[string]string {
+	lib.mutex.RLock()
+	defer lib.mutex.RUnlock()
+
+	assignments := make(map[string]string, len(lib.assignments))
+	for key, track := range lib.assignments {
+		assignments[key] = track
+	}
+	return assignments
+}
+
+// Assign assigns the track to the cue key, persisting the assignments to the library directory
+func (lib *AudioLibrary) Assign(key, name string) error {
+	lib.mutex.Lock()
+	defer lib.mutex.Unlock()
+
+	if !validAudioKey(key) {
+		return fmt.Errorf("%w '%s'", ErrUndefinedAudioKey, key)
+	}
+	if err := lib.validate(name); err != nil {
+		return err
+	}
+	lib.assignments[key] = name
+	return lib.save()
+}
+
+// save persists the assignments; the file is replaced atomically
+func (lib *AudioLibrary) save() error {
+	data, err := json.MarshalIndent(lib.assignments, "", "  ")
+	if err != nil {
+		return err
+	}
+
+	return atomicfile.WriteFile(filepath.Join(lib.dir, assignmentsFile), data, 0644)
+}
+
+// Path returns the file of the track assigned to the cue; tracks which are missing or do not decode are skipped
+// in favour of less specific assignments (i.e. the dhuhr or adhan track for jumuah)
+func (lib *AudioLibrary) Path(cue Cue) string {
+	lib.mutex.RLock()
+	defer lib.mutex.RUnlock()
+	return resolveAudio(lib.dir, lib.assignments, cue, func(track string) bool { return lib.validate(track) == nil })
+}
+
+// Wrap returns a player which plays the tracks of the library assigned to cues on the player
+func (lib *AudioLibrary) Wrap(player Player) Player {
+	return libraryPlayer{player: player, library: lib}
+}
+
+func validAudioKey(key string) bool {
+	for _, k := range AudioKeys {
+		if k == key {
+			return true
+		}
+	}
+	return false
+}
+
+// libraryPlayer sets the file of cues to their assigned track prior to playing them
+type libraryPlayer struct {
+	player  Player
+	library *AudioLibrary
+}
+
+func (lp libraryPlayer) Play(ctx context.Context, cue Cue) error {
+	cue.File = lp.library.Path(cue)
+	return lp.player.Play(ctx, cue)
+}
+
+// Close stops in-flight playback of the player, if it holds resources
+func (lp libraryPlayer) Close() error {
+	if closer, ok := lp.player.(io.Closer); ok {
+		return closer.Close()
+	}
+	return nil
+}
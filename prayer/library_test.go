@@ -0,0 +1,266 @@
+package prayer
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/zees-dev/prayeralarm/aladhan"
+)
+
+// newTestLibrary returns an audio library of a valid track (`adhan.mp3`) and a track which does not decode
+func newTestLibrary(t *testing.T) (*AudioLibrary, string) {
+	dir := t.TempDir()
+	data, err := ioutil.ReadFile("../mp3/test.mp3")
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	if err := ioutil.WriteFile(filepath.Join(dir, "adhan.mp3"), data, 0644); err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	if err := ioutil.WriteFile(filepath.Join(dir, "broken.mp3"), []byte("not an mp3"), 0644); err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	if err := ioutil.WriteFile(filepath.Join(dir, "notes.txt"), []byte("not a track"), 0644); err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+
+	lib, err := NewAudioLibrary(dir)
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	return lib, dir
+}
+
+func TestAudioLibrary(t *testing.T) {
+	t.Run("test tracks are scanned and validated", func(t *testing.T) {
+		lib, _ := newTestLibrary(t)
+		tracks := lib.Tracks()
+		if len(tracks) != 2 {
+			t.Fatalf("want 2 tracks, got %+v", tracks)
+		}
+		if tracks[0].Name != "adhan.mp3" || tracks[0].Error != "" || tracks[0].Duration <= 0 {
+			t.Errorf("want valid adhan.mp3 track, got %+v", tracks[0])
+		}
+		if tracks[1].Name != "broken.mp3" || tracks[1].Error == "" {
+			t.Errorf("want invalid broken.mp3 track, got %+v", tracks[1])
+		}
+	})
+
+	t.Run("test default assignments", func(t *testing.T) {
+		lib, dir := newTestLibrary(t)
+		tests := map[Cue]string{
+			{Adhan: aladhan.Fajr}:                   "adhan-fajr.mp3",
+			{Adhan: aladhan.Isha}:                   "adhan-turkish.mp3",
+			{Adhan: aladhan.Isha, Alert: REMINDER}:  "reminder.mp3",
+			{Adhan: aladhan.Fajr, Mode: NOTIFY}:     "notification.mp3",
+			{Adhan: aladhan.Tahajjud}:               "tahajjud.mp3",
+			{Adhan: aladhan.Maghrib, Alert: IFTAR}:  "iftar.mp3",
+			{Adhan: aladhan.Dhuhr, Alert: IQAMAH}:   "iqamah.mp3",
+			{Adhan: aladhan.Jumuah, Volume: 50}:     "adhan-turkish.mp3",
+			{Adhan: aladhan.Asr, Mode: SHORT}:       "adhan-turkish.mp3",
+			{Adhan: aladhan.Fajr, Alert: SUHOOR}:    "suhoor.mp3",
+			{Adhan: aladhan.Maghrib, Mode: FULL}:    "adhan-turkish.mp3",
+			{Adhan: aladhan.Dhuhr, Alert: REMINDER}: "reminder.mp3",
+		}
+		for cue, track := range tests {
+			if want, got := filepath.Join(dir, track), lib.Path(cue); got != want {
+				t.Errorf("want %s, got %s", want, got)
+			}
+		}
+	})
+
+	t.Run("test assigned tracks are persisted", func(t *testing.T) {
+		lib, dir := newTestLibrary(t)
+		if err := lib.Assign("isha", "adhan.mp3"); err != nil {
+			t.Fatalf("unexpected error: %s", err)
+		}
+
+		reloaded, err := NewAudioLibrary(dir)
+		if err != nil {
+			t.Fatalf("unexpected error: %s", err)
+		}
+		if want, got := filepath.Join(dir, "adhan.mp3"), reloaded.Path(Cue{Adhan: aladhan.Isha}); got != want {
+			t.Errorf("want %s, got %s", want, got)
+		}
+		if want, got := filepath.Join(dir, "adhan-turkish.mp3"), reloaded.Path(Cue{Adhan: aladhan.Asr}); got != want {
+			t.Errorf("want %s, got %s", want, got)
+		}
+	})
+
+	t.Run("test unplayable assignments fall back to less specific tracks", func(t *testing.T) {
+		dir := t.TempDir()
+		data, err := ioutil.ReadFile("../mp3/test.mp3")
+		if err != nil {
+			t.Fatalf("unexpected error: %s", err)
+		}
+		ioutil.WriteFile(filepath.Join(dir, "dhuhr.mp3"), data, 0644)
+		ioutil.WriteFile(filepath.Join(dir, "broken.mp3"), []byte("not an mp3"), 0644)
+		ioutil.WriteFile(filepath.Join(dir, assignmentsFile), []byte(`{"jumuah": "missing.mp3", "dhuhr": "dhuhr.mp3", "isha": "broken.mp3"}`), 0644)
+
+		lib, err := NewAudioLibrary(dir)
+		if err != nil {
+			t.Fatalf("unexpected error: %s", err)
+		}
+		if want, got := filepath.Join(dir, "dhuhr.mp3"), lib.Path(Cue{Adhan: aladhan.Jumuah}); got != want {
+			t.Errorf("want %s, got %s", want, got)
+		}
+		// No playable track is assigned to isha or adhan; the most specific track is played (and its error logged)
+		if want, got := filepath.Join(dir, "broken.mp3"), lib.Path(Cue{Adhan: aladhan.Isha}); got != want {
+			t.Errorf("want %s, got %s", want, got)
+		}
+	})
+
+	t.Run("test missing default tracks fall back to the adhan or notification track", func(t *testing.T) {
+		dir := t.TempDir()
+		data, err := ioutil.ReadFile("../mp3/test.mp3")
+		if err != nil {
+			t.Fatalf("unexpected error: %s", err)
+		}
+		ioutil.WriteFile(filepath.Join(dir, defaultAssignments["adhan"]), data, 0644)
+
+		lib, err := NewAudioLibrary(dir)
+		if err != nil {
+			t.Fatalf("unexpected error: %s", err)
+		}
+		tests := map[Cue]string{
+			{Adhan: aladhan.Fajr}:                  defaultAssignments["adhan"],
+			{Adhan: aladhan.Tahajjud}:              defaultAssignments["adhan"],
+			{Adhan: aladhan.Isha, Mode: NOTIFY}:    defaultAssignments["adhan"],
+			{Adhan: aladhan.Isha, Alert: REMINDER}: defaultAssignments[string(REMINDER)],
+		}
+		for cue, track := range tests {
+			if want, got := filepath.Join(dir, track), lib.Path(cue); got != want {
+				t.Errorf("%s: want %s, got %s", cue, want, got)
+			}
+		}
+
+		ioutil.WriteFile(filepath.Join(dir, defaultAssignments["notification"]), data, 0644)
+		lib, err = NewAudioLibrary(dir)
+		if err != nil {
+			t.Fatalf("unexpected error: %s", err)
+		}
+		for _, alert := range []AlertType{REMINDER, IQAMAH, SUHOOR, IFTAR} {
+			if want, got := filepath.Join(dir, defaultAssignments["notification"]), lib.Path(Cue{Adhan: aladhan.Isha, Alert: alert}); got != want {
+				t.Errorf("%s: want %s, got %s", alert, want, got)
+			}
+		}
+	})
+
+	t.Run("test malformed assignments fall back to default assignments", func(t *testing.T) {
+		_, dir := newTestLibrary(t)
+		ioutil.WriteFile(filepath.Join(dir, assignmentsFile), []byte(`{"fajr": "adhan.mp3"`), 0644)
+
+		lib, err := NewAudioLibrary(dir)
+		if err != nil {
+			t.Fatalf("unexpected error: %s", err)
+		}
+		if want, got := filepath.Join(dir, "adhan-fajr.mp3"), lib.Path(Cue{Adhan: aladhan.Fajr}); got != want {
+			t.Errorf("want %s, got %s", want, got)
+		}
+	})
+
+	t.Run("test invalid assignments", func(t *testing.T) {
+		lib, _ := newTestLibrary(t)
+		for key, track := range map[string]string{"isha": "broken.mp3", "asr": "missing.mp3", "sunrise": "adhan.mp3"} {
+			if err := lib.Assign(key, track); err == nil {
+				t.Errorf("want error assigning %s to %s, got nil", track, key)
+			}
+		}
+	})
+
+	t.Run("test wrapped player plays assigned tracks", func(t *testing.T) {
+		lib, dir := newTestLibrary(t)
+		player := &recordingPlayer{}
+		if err := lib.Wrap(player).Play(context.Background(), Cue{Adhan: aladhan.Fajr}); err != nil {
+			t.Fatalf("unexpected error: %s", err)
+		}
+		if want := filepath.Join(dir, "adhan-fajr.mp3"); len(player.cues) != 1 || player.cues[0].File != want {
+			t.Errorf("want %s, got %+v", want, player.cues)
+		}
+	})
+}
+
+func TestAudioLibraryManagement(t *testing.T) {
+	t.Run("test added tracks are validated", func(t *testing.T) {
+		lib, dir := newTestLibrary(t)
+		data, err := ioutil.ReadFile(filepath.Join(dir, "adhan.mp3"))
+		if err != nil {
+			t.Fatalf("unexpected error: %s", err)
+		}
+
+		track, err := lib.Add("adhan-makkah.mp3", bytes.NewReader(data))
+		if err != nil {
+			t.Fatalf("unexpected error: %s", err)
+		}
+		if track.Name != "adhan-makkah.mp3" || track.Duration <= 0 {
+			t.Errorf("want valid adhan-makkah.mp3 track, got %+v", track)
+		}
+		if _, err := os.Stat(filepath.Join(dir, "adhan-makkah.mp3")); err != nil {
+			t.Errorf("want track file, got %s", err)
+		}
+
+		tests := []struct {
+			name string
+			data []byte
+			want error
+		}{
+			{"adhan.mp3", data, ErrTrackExists},
+			{"../adhan.mp3", data, ErrInvalidTrack},
+			{"adhan.wav", data, ErrInvalidTrack},
+			{"noise.mp3", []byte("not an mp3"), ErrInvalidTrack},
+		}
+		for _, tt := range tests {
+			if _, err := lib.Add(tt.name, bytes.NewReader(tt.data)); !errors.Is(err, tt.want) {
+				t.Errorf("want %s adding %s, got %v", tt.want, tt.name, err)
+			}
+		}
+		if len(lib.Tracks()) != 3 {
+			t.Errorf("want 3 tracks, got %+v", lib.Tracks())
+		}
+	})
+
+	t.Run("test renamed tracks keep their assignments", func(t *testing.T) {
+		lib, dir := newTestLibrary(t)
+		if err := lib.Assign("fajr", "adhan.mp3"); err != nil {
+			t.Fatalf("unexpected error: %s", err)
+		}
+		if err := lib.Rename("adhan.mp3", "adhan-fajr-makkah.mp3"); err != nil {
+			t.Fatalf("unexpected error: %s", err)
+		}
+
+		if want, got := filepath.Join(dir, "adhan-fajr-makkah.mp3"), lib.Path(Cue{Adhan: aladhan.Fajr}); got != want {
+			t.Errorf("want %s, got %s", want, got)
+		}
+		if err := lib.Rename("adhan.mp3", "other.mp3"); !errors.Is(err, ErrTrackNotFound) {
+			t.Errorf("want %s, got %v", ErrTrackNotFound, err)
+		}
+		if err := lib.Rename("adhan-fajr-makkah.mp3", "broken.mp3"); !errors.Is(err, ErrTrackExists) {
+			t.Errorf("want %s, got %v", ErrTrackExists, err)
+		}
+	})
+
+	t.Run("test assigned tracks are not deleted", func(t *testing.T) {
+		lib, dir := newTestLibrary(t)
+		if err := lib.Assign("isha", "adhan.mp3"); err != nil {
+			t.Fatalf("unexpected error: %s", err)
+		}
+		if err := lib.Delete("adhan.mp3"); !errors.Is(err, ErrTrackAssigned) {
+			t.Errorf("want %s, got %v", ErrTrackAssigned, err)
+		}
+
+		if err := lib.Delete("broken.mp3"); err != nil {
+			t.Fatalf("unexpected error: %s", err)
+		}
+		if _, err := os.Stat(filepath.Join(dir, "broken.mp3")); !os.IsNotExist(err) {
+			t.Errorf("want track file to be deleted, got %v", err)
+		}
+		if _, err := lib.TrackPath("broken.mp3"); !errors.Is(err, ErrTrackNotFound) {
+			t.Errorf("want %s, got %v", ErrTrackNotFound, err)
+		}
+	})
+}
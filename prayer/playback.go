@@ -0,0 +1,200 @@
+package prayer
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/zees-dev/prayeralarm/aladhan"
+)
+
+// PlaybackMode determines how the audio of a cue is played
+type PlaybackMode string
+
+const (
+	FULL   PlaybackMode = "full"
+	SHORT  PlaybackMode = "short"
+	NOTIFY PlaybackMode = "notify"
+	MUTE   PlaybackMode = "mute"
+)
+
+// playbackModeLevels orders playback modes from the loudest to the quietest
+var playbackModeLevels = map[PlaybackMode]int{FULL: 0, SHORT: 1, NOTIFY: 2, MUTE: 3}
+
+const (
+	// shortPlaybackDuration is the duration of the audio played in `SHORT` playback mode
+	shortPlaybackDuration = 30 * time.Second
+	// maxVolume is the full playback volume (in percent)
+	maxVolume = 100
+)
+
+// PlaybackPolicy determines the playback mode and volume of cues by adhan; cues of alerts follow the policy
+// of their adhan. During quiet hours, the quieter of the adhan and quiet hours mode (and volume) is applied.
+// Cues fade in (and out) over FadeIn (and FadeOut); 0 for no fade. Playback exceeding Timeout is stopped;
+// 0 for no timeout.
+type PlaybackPolicy struct {
+	Modes      map[aladhan.Adhan]PlaybackMode `json:"modes"`
+	Volumes    map[aladhan.Adhan]int          `json:"volumes"`
+	QuietHours *QuietHours                    `json:"quietHours,omitempty"`
+	FadeIn     time.Duration                  `json:"fadeIn"`
+	FadeOut    time.Duration                  `json:"fadeOut"`
+	Timeout    time.Duration                  `json:"timeout"`
+}
+
+// QuietHours is a daily time window (i.e. "22:00" to "06:00", wrapping midnight) of quieter playback
+type QuietHours struct {
+	From   string       `json:"from"`
+	To     string       `json:"to"`
+	Mode   PlaybackMode `json:"mode"`
+	Volume int          `json:"volume"`
+}
+
+// apply sets the playback mode and volume of the cue played at t
+func (pp PlaybackPolicy) apply(cue Cue, t time.Time) Cue {
+	adhan := settingsAdhan(cue.Adhan)
+	cue.Mode, cue.Volume = FULL, maxVolume
+	cue.FadeIn, cue.FadeOut = pp.FadeIn, pp.FadeOut
+	if mode, ok := pp.Modes[adhan]; ok {
+		cue.Mode = mode
+	}
+	if volume, ok := pp.Volumes[adhan]; ok {
+		cue.Volume = volume
+	}
+
+	if pp.QuietHours != nil && pp.QuietHours.contains(t) {
+		if playbackModeLevels[pp.QuietHours.Mode] > playbackModeLevels[cue.Mode] {
+			cue.Mode = pp.QuietHours.Mode
+		}
+		if pp.QuietHours.Volume < cue.Volume {
+			cue.Volume = pp.QuietHours.Volume
+		}
+	}
+	return cue
+}
+
+// clone returns a copy of the policy, which does not share the modes, volumes and quiet hours of the policy
+func (pp PlaybackPolicy) clone() PlaybackPolicy {
+	modes := make(map[aladhan.Adhan]PlaybackMode, len(pp.Modes))
+	for adhan, mode := range pp.Modes {
+		modes[adhan] = mode
+	}
+	volumes := make(map[aladhan.Adhan]int, len(pp.Volumes))
+	for adhan, volume := range pp.Volumes {
+		volumes[adhan] = volume
+	}
+	pp.Modes, pp.Volumes = modes, volumes
+	if pp.QuietHours != nil {
+		quietHours := *pp.QuietHours
+		pp.QuietHours = &quietHours
+	}
+	return pp
+}
+
+// Validate reports whether the playback modes, volumes, quiet hours and durations of the policy are valid
+func (pp PlaybackPolicy) Validate() error {
+	for adhan, mode := range pp.Modes {
+		if _, err := ParsePlaybackMode(string(mode)); err != nil {
+			return fmt.Errorf("invalid %s playback mode; %w", adhan, err)
+		}
+	}
+	for adhan, volume := range pp.Volumes {
+		if volume < 1 || volume > maxVolume {
+			return fmt.Errorf("invalid %s volume %d; volume must be between 1 and %d", adhan, volume, maxVolume)
+		}
+	}
+	if q := pp.QuietHours; q != nil {
+		if _, err := ParseQuietHours(fmt.Sprintf("%s-%s", q.From, q.To), string(q.Mode), q.Volume); err != nil {
+			return err
+		}
+	}
+	if pp.FadeIn < 0 || pp.FadeOut < 0 || pp.Timeout < 0 {
+		return fmt.Errorf("invalid playback durations; fade in, fade out and timeout must not be negative")
+	}
+	return nil
+}
+
+// contains reports whether the clock time of t is within the quiet hours
+func (q QuietHours) contains(t time.Time) bool {
+	from, err := time.Parse(iqamahClockFormat, q.From)
+	if err != nil {
+		return false
+	}
+	to, err := time.Parse(iqamahClockFormat, q.To)
+	if err != nil {
+		return false
+	}
+
+	mins := t.Hour()*60 + t.Minute()
+	fromMins, toMins := from.Hour()*60+from.Minute(), to.Hour()*60+to.Minute()
+	if fromMins <= toMins {
+		return mins >= fromMins && mins < toMins
+	}
+	return mins >= fromMins || mins < toMins
+}
+
+// ParsePlaybackMode parses the playback mode; `full`, `short`, `notify` or `mute`
+func ParsePlaybackMode(mode string) (PlaybackMode, error) {
+	if _, ok := playbackModeLevels[PlaybackMode(mode)]; !ok {
+		return "", fmt.Errorf("undefined playback mode '%s'", mode)
+	}
+	return PlaybackMode(mode), nil
+}
+
+// ParsePlaybackModes parses the comma separated playback modes for the 5 daily adhans
+func ParsePlaybackModes(modes string) (map[aladhan.Adhan]PlaybackMode, error) {
+	modeSlice := strings.Split(modes, ",")
+	if len(modeSlice) != 5 {
+		return nil, fmt.Errorf(`invalid playback modes "%s"; 5 comma separated modes are required`, modes)
+	}
+
+	parsed := make(map[aladhan.Adhan]PlaybackMode)
+	for i, adhan := range obligatoryAdhans {
+		mode, err := ParsePlaybackMode(strings.TrimSpace(modeSlice[i]))
+		if err != nil {
+			return nil, err
+		}
+		parsed[adhan] = mode
+	}
+	return parsed, nil
+}
+
+// ParseVolumes parses the comma separated playback volumes (in percent) for the 5 daily adhans
+func ParseVolumes(volumes string) (map[aladhan.Adhan]int, error) {
+	parsed, err := parseAdhanInts(volumes)
+	if err != nil {
+		return nil, fmt.Errorf(`invalid volumes "%s"; err=%w`, volumes, err)
+	}
+
+	for _, adhan := range obligatoryAdhans {
+		if parsed[adhan] < 1 || parsed[adhan] > maxVolume {
+			return nil, fmt.Errorf(`invalid volumes "%s"; volumes must be between 1 and %d`, volumes, maxVolume)
+		}
+	}
+	return parsed, nil
+}
+
+// ParseQuietHours parses the quiet hours window (`HH:MM-HH:MM`) along with its playback mode and volume;
+// nil is returned for an empty window
+func ParseQuietHours(window string, mode string, volume int) (*QuietHours, error) {
+	if window == "" {
+		return nil, nil
+	}
+
+	clocks := strings.Split(window, "-")
+	if len(clocks) != 2 {
+		return nil, fmt.Errorf(`invalid quiet hours "%s"; HH:MM-HH:MM is required`, window)
+	}
+	for _, clock := range clocks {
+		if _, err := time.Parse(iqamahClockFormat, clock); err != nil {
+			return nil, fmt.Errorf(`invalid quiet hours "%s"; HH:MM-HH:MM is required`, window)
+		}
+	}
+	playbackMode, err := ParsePlaybackMode(mode)
+	if err != nil {
+		return nil, err
+	}
+	if volume < 1 || volume > maxVolume {
+		return nil, fmt.Errorf("invalid quiet hours volume %d; volume must be between 1 and %d", volume, maxVolume)
+	}
+	return &QuietHours{From: clocks[0], To: clocks[1], Mode: playbackMode, Volume: volume}, nil
+}
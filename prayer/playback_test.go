@@ -0,0 +1,208 @@
+package prayer
+
+import (
+	"bytes"
+	"context"
+	"encoding/binary"
+	"io/ioutil"
+	"testing"
+	"time"
+
+	"github.com/zees-dev/prayeralarm/aladhan"
+)
+
+func TestPlaybackPolicy(t *testing.T) {
+	policy := PlaybackPolicy{
+		Modes:      map[aladhan.Adhan]PlaybackMode{aladhan.Fajr: SHORT, aladhan.Dhuhr: FULL, aladhan.Isha: NOTIFY},
+		Volumes:    map[aladhan.Adhan]int{aladhan.Fajr: 80, aladhan.Dhuhr: 100, aladhan.Isha: 20},
+		QuietHours: &QuietHours{From: "22:00", To: "06:00", Mode: SHORT, Volume: 50},
+	}
+	at := func(hour, min int) time.Time {
+		return time.Date(2021, 1, 1, hour, min, 0, 0, time.UTC)
+	}
+
+	tests := []struct {
+		name       string
+		cue        Cue
+		t          time.Time
+		wantMode   PlaybackMode
+		wantVolume int
+	}{
+		{"test adhan policy outside of quiet hours", Cue{Adhan: aladhan.Fajr}, at(6, 0), SHORT, 80},
+		{"test quiet hours past midnight", Cue{Adhan: aladhan.Fajr}, at(4, 30), SHORT, 50},
+		{"test quiet hours prior to midnight", Cue{Adhan: aladhan.Dhuhr}, at(22, 0), SHORT, 50},
+		{"test quieter adhan policy during quiet hours", Cue{Adhan: aladhan.Isha}, at(23, 0), NOTIFY, 20},
+		{"test alerts follow adhan policy", Cue{Adhan: aladhan.Isha, Alert: REMINDER}, at(19, 0), NOTIFY, 20},
+		{"test jumuah follows dhuhr policy", Cue{Adhan: aladhan.Jumuah}, at(13, 0), FULL, 100},
+		{"test full playback without adhan policy", Cue{Adhan: aladhan.Tahajjud}, at(21, 0), FULL, 100},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := policy.apply(tt.cue, tt.t)
+			if got.Mode != tt.wantMode {
+				t.Errorf("want %s, got %s", tt.wantMode, got.Mode)
+			}
+			if got.Volume != tt.wantVolume {
+				t.Errorf("want %d, got %d", tt.wantVolume, got.Volume)
+			}
+		})
+	}
+}
+
+func TestParsePlaybackPolicy(t *testing.T) {
+	t.Run("test playback modes", func(t *testing.T) {
+		got, err := ParsePlaybackModes("short, full,full,notify,mute")
+		if err != nil {
+			t.Fatalf("unexpected error: %s", err)
+		}
+		if got[aladhan.Fajr] != SHORT || got[aladhan.Maghrib] != NOTIFY || got[aladhan.Isha] != MUTE {
+			t.Errorf("want short fajr, notify maghrib and mute isha, got %v", got)
+		}
+	})
+
+	t.Run("test invalid playback modes", func(t *testing.T) {
+		for _, modes := range []string{"short,full", "loud,full,full,full,full"} {
+			if _, err := ParsePlaybackModes(modes); err == nil {
+				t.Errorf("want error for %s, got nil", modes)
+			}
+		}
+	})
+
+	t.Run("test invalid volumes", func(t *testing.T) {
+		for _, volumes := range []string{"100,100", "0,100,100,100,100", "101,100,100,100,100"} {
+			if _, err := ParseVolumes(volumes); err == nil {
+				t.Errorf("want error for %s, got nil", volumes)
+			}
+		}
+	})
+
+	t.Run("test quiet hours", func(t *testing.T) {
+		got, err := ParseQuietHours("22:00-06:00", "notify", 30)
+		if err != nil {
+			t.Fatalf("unexpected error: %s", err)
+		}
+		want := QuietHours{From: "22:00", To: "06:00", Mode: NOTIFY, Volume: 30}
+		if *got != want {
+			t.Errorf("want %+v, got %+v", want, *got)
+		}
+		if got, err := ParseQuietHours("", "notify", 30); err != nil || got != nil {
+			t.Errorf("want no quiet hours, got %+v (err=%v)", got, err)
+		}
+		if _, err := ParseQuietHours("22:00", "notify", 30); err == nil {
+			t.Error("want error, got nil")
+		}
+	})
+
+	t.Run("test invalid playback policies", func(t *testing.T) {
+		policies := []PlaybackPolicy{
+			{Modes: map[aladhan.Adhan]PlaybackMode{aladhan.Fajr: "loud"}},
+			{Volumes: map[aladhan.Adhan]int{aladhan.Isha: 0}},
+			{QuietHours: &QuietHours{From: "22:00", To: "6am", Mode: SHORT, Volume: 50}},
+			{FadeIn: -time.Second},
+		}
+		for _, policy := range policies {
+			if err := policy.Validate(); err == nil {
+				t.Errorf("want error for %+v, got nil", policy)
+			}
+		}
+		valid := PlaybackPolicy{Volumes: map[aladhan.Adhan]int{aladhan.Isha: 30}, FadeIn: 5 * time.Second}
+		if err := valid.Validate(); err != nil {
+			t.Errorf("unexpected error: %s", err)
+		}
+	})
+}
+
+func TestVolumeReader(t *testing.T) {
+	// pcm returns the 16-bit little-endian PCM bytes of the samples
+	pcm := func(samples ...int16) *bytes.Buffer {
+		var buf bytes.Buffer
+		binary.Write(&buf, binary.LittleEndian, samples)
+		return &buf
+	}
+	assertSamples := func(t *testing.T, reader *volumeReader, want []int16) {
+		got, err := ioutil.ReadAll(reader)
+		if err != nil {
+			t.Fatalf("unexpected error: %s", err)
+		}
+		if len(got) != len(want)*2 {
+			t.Fatalf("want %d samples, got %d bytes", len(want), len(got))
+		}
+		for i := range want {
+			if sample := int16(binary.LittleEndian.Uint16(got[i*2:])); sample != want[i] {
+				t.Errorf("want %d, got %d", want[i], sample)
+			}
+		}
+	}
+
+	t.Run("test samples are scaled by volume", func(t *testing.T) {
+		reader := &volumeReader{reader: pcm(1000, -1000, 32767, -32768), volume: 50, frames: -1}
+		assertSamples(t, reader, []int16{500, -500, 16383, -16384})
+	})
+
+	t.Run("test frames fade in and out", func(t *testing.T) {
+		// 6 stereo frames; fading in over the first 2 frames and out over the last 2 frames
+		samples := []int16{1000, 1000, 1000, 1000, 1000, 1000, 1000, 1000, 1000, 1000, 1000, 1000}
+		reader := &volumeReader{reader: pcm(samples...), volume: maxVolume, fadeIn: 2, fadeOut: 2, frames: 6}
+		assertSamples(t, reader, []int16{0, 0, 500, 500, 1000, 1000, 1000, 1000, 1000, 1000, 500, 500})
+	})
+
+	t.Run("test fade out is skipped for unknown length", func(t *testing.T) {
+		reader := &volumeReader{reader: pcm(1000, 1000, 1000, 1000), volume: 50, fadeOut: 2, frames: -1}
+		assertSamples(t, reader, []int16{500, 500, 500, 500})
+	})
+}
+
+// recordingPlayer records the played cues
+type recordingPlayer struct {
+	cues []Cue
+}
+
+func (rp *recordingPlayer) Play(ctx context.Context, cue Cue) error {
+	rp.cues = append(rp.cues, cue)
+	return nil
+}
+
+func TestScheduleCuePlaybackPolicy(t *testing.T) {
+	player := &recordingPlayer{}
+	settings := Settings{Playback: PlaybackPolicy{Modes: map[aladhan.Adhan]PlaybackMode{aladhan.Fajr: MUTE, aladhan.Isha: SHORT}}}
+	svc := NewService(player, NewPrayerDatabase(), stubProvider{}, settings)
+
+	svc.scheduleCue("fajr", true, time.Now().Add(time.Hour), Cue{Adhan: aladhan.Fajr})
+	svc.scheduleCue("isha", true, time.Now().Add(2*time.Hour), Cue{Adhan: aladhan.Isha})
+	for _, event := range svc.scheduler.Events() {
+		event.Action(context.Background())
+	}
+
+	if len(player.cues) != 1 {
+		t.Fatalf("want muted fajr to be skipped, got %v", player.cues)
+	}
+	if want := (Cue{Adhan: aladhan.Isha, Mode: SHORT, Volume: maxVolume}); player.cues[0] != want {
+		t.Errorf("want %+v, got %+v", want, player.cues[0])
+	}
+}
+
+func TestSetPlaybackPolicy(t *testing.T) {
+	player := &recordingPlayer{}
+	svc := NewService(player, NewPrayerDatabase(), stubProvider{}, Settings{})
+	svc.scheduleCue("fajr", true, time.Now().Add(time.Hour), Cue{Adhan: aladhan.Fajr})
+
+	if err := svc.SetPlaybackPolicy(PlaybackPolicy{Volumes: map[aladhan.Adhan]int{aladhan.Fajr: 101}}); err == nil {
+		t.Error("want error, got nil")
+	}
+	policy := svc.GetPlaybackPolicy()
+	policy.Volumes[aladhan.Fajr] = 40
+	policy.FadeIn = 5 * time.Second
+	if err := svc.SetPlaybackPolicy(policy); err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	// Modifying the set policy does not modify the service policy
+	policy.Volumes[aladhan.Fajr] = 100
+
+	for _, event := range svc.scheduler.Events() {
+		event.Action(context.Background())
+	}
+	want := Cue{Adhan: aladhan.Fajr, Mode: FULL, Volume: 40, FadeIn: 5 * time.Second}
+	if len(player.cues) != 1 || player.cues[0] != want {
+		t.Errorf("want %+v, got %+v", want, player.cues)
+	}
+}
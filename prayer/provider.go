@@ -0,0 +1,320 @@
+package prayer
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"strconv"
+	"time"
+
+	"github.com/zees-dev/prayeralarm/aladhan"
+	"github.com/zees-dev/prayeralarm/praytime"
+)
+
+// CalendarProvider retrieves the adhan timings of a month for a location
+type CalendarProvider interface {
+	MonthCalendar(ctx context.Context, location Location, year int, month time.Month) (Calendar, error)
+}
+
+type Provider string
+
+const (
+	ALADHAN     Provider = "aladhan"
+	CALCULATION Provider = "calculation"
+)
+
+// GetCalendarProvider returns the calendar provider for the settings;
+// the Adhan API provider uses the client, caches calendars in cacheDir (unless empty) and falls back
+// to offline calculation when the location has coordinates
+func GetCalendarProvider(provider Provider, settings Settings, client *aladhan.Client, cacheDir string) (CalendarProvider, error) {
+	switch provider {
+	case ALADHAN:
+		// The Adhan API applies its default adjustment when none is requested, unlike the calculation provider
+		if settings.Calculation.HighLatitude == praytime.NoAdjustment {
+			return nil, fmt.Errorf("high latitude adjustment '%s' is not supported by the %s provider", praytime.NoAdjustment, ALADHAN)
+		}
+		api := NewAladhanProvider(client, settings, cacheDir)
+		if !settings.Location.hasCoordinates() {
+			return api, nil
+		}
+		calculation, err := NewCalculationProvider(settings)
+		if err != nil {
+			return nil, err
+		}
+		return NewFallbackProvider(api, calculation), nil
+	case CALCULATION:
+		if !settings.Location.hasCoordinates() {
+			return nil, errors.New("latitude and longitude are required for calculation provider")
+		}
+		return NewCalculationProvider(settings)
+	default:
+		return nil, fmt.Errorf("undefined calendar provider '%s'", provider)
+	}
+}
+
+// Location identifies the place for which adhan timings are retrieved;
+// by coordinates, address, or city and country (in order of precedence)
+type Location struct {
+	City      string  `json:"city"`
+	Country   string  `json:"country"`
+	Address   string  `json:"address"`
+	Latitude  float64 `json:"latitude"`
+	Longitude float64 `json:"longitude"`
+	Elevation float64 `json:"elevation"`
+	Timezone  string  `json:"timezone"`
+}
+
+func (l Location) hasCoordinates() bool {
+	return l.Latitude != 0 || l.Longitude != 0
+}
+
+// Calendar holds the adhan timings of a month along with the resolved location
+// (coordinates and timezone) of the timings
+type Calendar struct {
+	Location Location
+	Days     []CalendarDay
+}
+
+// CalendarDay holds the normalized adhan (and non-adhan) timings of a single day
+type CalendarDay struct {
+	Date    time.Time
+	Hijri   praytime.HijriDate
+	Timings map[aladhan.Adhan]time.Time
+}
+
+// aladhanProvider retrieves adhan timings from the Adhan API
+type aladhanProvider struct {
+	client  *aladhan.Client
+	params  aladhan.Params
+	offsets string
+	cache   *aladhan.Cache
+}
+
+// NewAladhanProvider returns a calendar provider backed by the Adhan API client using the calculation parameters,
+// adhan offsets and hijri adjustment of the settings.
+// Retrieved calendars are cached in cacheDir (unless empty) and used when the API is unreachable.
+func NewAladhanProvider(client *aladhan.Client, settings Settings, cacheDir string) aladhanProvider {
+	params := aladhanParams(settings.Calculation)
+	params.HijriAdjustment = settings.HijriAdjustment
+	ap := aladhanProvider{client: client, params: params, offsets: settings.Offsets}
+	if cacheDir != "" {
+		ap.cache = aladhan.NewCache(cacheDir)
+	}
+	return ap
+}
+
+// aladhanParams converts calculation parameters to their Adhan API equivalent
+func aladhanParams(params praytime.Params) aladhan.Params {
+	apiParams := aladhan.Params{
+		Method:                   params.Method.ID,
+		School:                   int(params.School),
+		LatitudeAdjustmentMethod: int(params.HighLatitude),
+		MidnightMode:             int(params.Midnight),
+	}
+
+	if params.Method.ID == praytime.CustomMethodID {
+		setting := func(angle, minutes float64) string {
+			switch {
+			case minutes > 0:
+				return fmt.Sprintf("%g min", minutes)
+			case angle > 0:
+				return fmt.Sprintf("%g", angle)
+			default:
+				return "null"
+			}
+		}
+		method := params.Method
+		apiParams.MethodSettings = fmt.Sprintf(
+			"%g,%s,%s",
+			method.Fajr,
+			setting(method.Maghrib, method.MaghribMinutes),
+			setting(method.Isha, method.IshaMinutes),
+		)
+	}
+	return apiParams
+}
+
+// MonthCalendar retrieves the monthly calendar from the Adhan API and normalizes its timings
+func (ap aladhanProvider) MonthCalendar(ctx context.Context, location Location, year int, month time.Month) (Calendar, error) {
+	req := aladhan.CalendarRequest{
+		City:      location.City,
+		Country:   location.Country,
+		Address:   location.Address,
+		Latitude:  location.Latitude,
+		Longitude: location.Longitude,
+		Offsets:   ap.offsets,
+		Month:     month,
+		Year:      year,
+		Params:    ap.params,
+	}
+	monthCalendar, err := ap.getMonthCalendar(ctx, req)
+	if err != nil {
+		return Calendar{}, err
+	}
+
+	calendar := Calendar{Location: location, Days: make([]CalendarDay, 0, len(monthCalendar.Data))}
+	for _, timings := range monthCalendar.Data {
+		date, err := getDate(timings.Date.Readable, timings.Meta.Timezone)
+		if err != nil {
+			return Calendar{}, err
+		}
+
+		hijri, err := getHijriDate(timings.Date.Hijri.Day, timings.Date.Hijri.Month.Number, timings.Date.Hijri.Year)
+		if err != nil {
+			return Calendar{}, err
+		}
+		hijri.Month.En, hijri.Month.Ar = timings.Date.Hijri.Month.En, timings.Date.Hijri.Month.Ar
+
+		day := CalendarDay{Date: date, Hijri: hijri, Timings: make(map[aladhan.Adhan]time.Time, len(timings.Timings))}
+		for adhan, timeStr := range timings.Timings {
+			fullTimeStr := fmt.Sprintf("%s %s", timings.Date.Readable, timeStr)
+			adhanTime, err := getTime(fullTimeStr, timings.Meta.Timezone)
+			if err != nil {
+				return Calendar{}, err
+			}
+			day.Timings[adhan] = adhanTime
+		}
+		// Timings of the evening (i.e. midnight) may be past midnight, on the following day
+		if dhuhr, ok := day.Timings[aladhan.Dhuhr]; ok {
+			for _, adhan := range []aladhan.Adhan{aladhan.Asr, aladhan.Sunset, aladhan.Maghrib, aladhan.Isha, aladhan.Midnight} {
+				if adhanTime, ok := day.Timings[adhan]; ok && adhanTime.Before(dhuhr) {
+					day.Timings[adhan] = adhanTime.AddDate(0, 0, 1)
+				}
+			}
+		}
+		calendar.Days = append(calendar.Days, day)
+
+		calendar.Location.Latitude = timings.Meta.Latitude
+		calendar.Location.Longitude = timings.Meta.Longitude
+		calendar.Location.Timezone = timings.Meta.Timezone
+	}
+
+	return calendar, nil
+}
+
+// getMonthCalendar retrieves the monthly calendar from the Adhan API, persisting it to the cache;
+// the cached calendar is returned when the API request fails
+func (ap aladhanProvider) getMonthCalendar(ctx context.Context, req aladhan.CalendarRequest) (aladhan.MonthlyAdhanCalenderResponse, error) {
+	monthCalendar, err := ap.client.GetMonthCalendar(ctx, req)
+	if ap.cache == nil {
+		return monthCalendar, err
+	}
+
+	if err != nil {
+		cached, cacheErr := ap.cache.Get(req)
+		if cacheErr != nil {
+			return monthCalendar, err
+		}
+		log.Printf("using cached calendar for %s %d: %s", req.Month, req.Year, err)
+		return cached, nil
+	}
+
+	if err := ap.cache.Put(req, monthCalendar); err != nil {
+		log.Printf("error caching calendar for %s %d: %s", req.Month, req.Year, err)
+	}
+	return monthCalendar, nil
+}
+
+// getHijriDate parses the hijri date of the Adhan API response
+func getHijriDate(day string, month int, year string) (praytime.HijriDate, error) {
+	d, err := strconv.Atoi(day)
+	if err != nil {
+		return praytime.HijriDate{}, fmt.Errorf(`incorrect hijri day input: "%s"`, day)
+	}
+	y, err := strconv.Atoi(year)
+	if err != nil {
+		return praytime.HijriDate{}, fmt.Errorf(`incorrect hijri year input: "%s"`, year)
+	}
+	if month < 1 || month > len(praytime.HijriMonths) {
+		return praytime.HijriDate{}, fmt.Errorf(`incorrect hijri month input: "%d"`, month)
+	}
+	return praytime.HijriDate{Day: d, Month: praytime.HijriMonths[month-1], Year: y}, nil
+}
+
+// calculationProvider calculates adhan timings offline from the location coordinates
+type calculationProvider struct {
+	params          praytime.Params
+	offsets         map[aladhan.Adhan]time.Duration
+	hijriAdjustment int
+}
+
+// NewCalculationProvider returns a calendar provider which calculates adhan timings with the calculation
+// parameters, adhan offsets and hijri adjustment of the settings
+func NewCalculationProvider(settings Settings) (calculationProvider, error) {
+	offsetSlice, err := aladhan.ParseOffsets(settings.Offsets)
+	if err != nil {
+		return calculationProvider{}, err
+	}
+
+	offsetDurations := make(map[aladhan.Adhan]time.Duration)
+	for i, adhan := range obligatoryAdhans {
+		offsetDurations[adhan] = time.Duration(offsetSlice[i]) * time.Minute
+	}
+	return calculationProvider{params: settings.Calculation, offsets: offsetDurations, hijriAdjustment: settings.HijriAdjustment}, nil
+}
+
+// MonthCalendar calculates the adhan timings for every day of the month at the location coordinates
+func (cp calculationProvider) MonthCalendar(ctx context.Context, location Location, year int, month time.Month) (Calendar, error) {
+	tl := time.Local
+	if location.Timezone != "" {
+		var err error
+		if tl, err = time.LoadLocation(location.Timezone); err != nil {
+			return Calendar{}, fmt.Errorf(`incorrect location input: "%s"`, location.Timezone)
+		}
+	}
+	coords := praytime.Coordinates{
+		Latitude:  location.Latitude,
+		Longitude: location.Longitude,
+		Elevation: location.Elevation,
+	}
+
+	calendar := Calendar{Location: location, Days: make([]CalendarDay, 0, 31)}
+	calendar.Location.Timezone = tl.String()
+	for date := time.Date(year, month, 1, 0, 0, 0, 0, tl); date.Month() == month; date = date.AddDate(0, 0, 1) {
+		times := praytime.Compute(date, coords, tl, cp.params)
+		day := CalendarDay{Date: date, Hijri: praytime.ToHijri(date, cp.hijriAdjustment), Timings: make(map[aladhan.Adhan]time.Time)}
+		for adhan, adhanTime := range map[aladhan.Adhan]time.Time{
+			aladhan.Imsak:    times.Imsak,
+			aladhan.Fajr:     times.Fajr,
+			aladhan.Sunrise:  times.Sunrise,
+			aladhan.Dhuhr:    times.Dhuhr,
+			aladhan.Asr:      times.Asr,
+			aladhan.Sunset:   times.Sunset,
+			aladhan.Maghrib:  times.Maghrib,
+			aladhan.Isha:     times.Isha,
+			aladhan.Midnight: times.Midnight,
+		} {
+			if adhanTime.IsZero() {
+				continue
+			}
+			day.Timings[adhan] = adhanTime.Add(cp.offsets[adhan])
+		}
+		calendar.Days = append(calendar.Days, day)
+	}
+
+	return calendar, nil
+}
+
+// fallbackProvider retrieves adhan timings from the first provider to succeed
+type fallbackProvider struct {
+	providers []CalendarProvider
+}
+
+// NewFallbackProvider returns a calendar provider which tries each provider in order
+func NewFallbackProvider(providers ...CalendarProvider) fallbackProvider {
+	return fallbackProvider{providers: providers}
+}
+
+func (fp fallbackProvider) MonthCalendar(ctx context.Context, location Location, year int, month time.Month) (Calendar, error) {
+	err := errors.New("no calendar providers")
+	for _, provider := range fp.providers {
+		var calendar Calendar
+		calendar, err = provider.MonthCalendar(ctx, location, year, month)
+		if err == nil || ctx.Err() != nil {
+			return calendar, err
+		}
+		log.Printf("calendar provider %T failed, trying next provider: %s", provider, err)
+	}
+	return Calendar{}, err
+}
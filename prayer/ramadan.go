@@ -0,0 +1,104 @@
+package prayer
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/zees-dev/prayeralarm/aladhan"
+	"github.com/zees-dev/prayeralarm/praytime"
+)
+
+type RamadanMode string
+
+const (
+	AUTO RamadanMode = "auto"
+	ON   RamadanMode = "on"
+	OFF  RamadanMode = "off"
+)
+
+// ParseRamadanMode parses the ramadan mode; `auto` (detected from the hijri month), `on` or `off`
+func ParseRamadanMode(mode string) (RamadanMode, error) {
+	switch RamadanMode(mode) {
+	case AUTO, ON, OFF:
+		return RamadanMode(mode), nil
+	default:
+		return "", fmt.Errorf("undefined ramadan mode '%s'", mode)
+	}
+}
+
+// Ramadan holds the settings of the suhoor and iftar alerts played during Ramadan;
+// SuhoorLeadTime is the number of mins prior to imsak (or fajr) at which the suhoor alert is played
+type Ramadan struct {
+	Mode           RamadanMode `json:"mode"`
+	SuhoorLeadTime int         `json:"suhoorLeadTime"`
+}
+
+// isRamadan reports whether the day of the hijri date is fasted
+func (r Ramadan) isRamadan(hijri praytime.HijriDate) bool {
+	switch r.Mode {
+	case AUTO:
+		return hijri.Month.Number == praytime.Ramadan
+	case ON:
+		return true
+	default:
+		return false
+	}
+}
+
+// fastStart returns the time at which the fast of the day starts; imsak if it is known, otherwise fajr
+func fastStart(day CalendarDay) (time.Time, bool) {
+	if imsak, ok := day.Timings[aladhan.Imsak]; ok {
+		return imsak, true
+	}
+	fajr, ok := day.Timings[aladhan.Fajr]
+	return fajr, ok
+}
+
+// FastingCountdown is the countdown to the next start (end of suhoor) or end (iftar) of the fast during Ramadan
+type FastingCountdown struct {
+	Ramadan bool       `json:"ramadan"`
+	Fasting bool       `json:"fasting"`
+	Next    AlertType  `json:"next,omitempty"`
+	Time    *time.Time `json:"time,omitempty"`
+	Seconds int64      `json:"seconds"`
+}
+
+// fastingCountdown returns the countdown to the next start or end of the fast of the prayer timings at t;
+// it is Ramadan if the day of the timings containing t is fasted
+func (r Ramadan) fastingCountdown(dailyPrayerTimings []DailyPrayerTimings, t time.Time) FastingCountdown {
+	var countdown FastingCountdown
+	for _, dpt := range dailyPrayerTimings {
+		year, month, day := t.In(dpt.Date.Location()).Date()
+		if dpt.Date.Year() == year && dpt.Date.Month() == month && dpt.Date.Day() == day {
+			countdown.Ramadan = r.isRamadan(dpt.Hijri)
+			break
+		}
+	}
+
+	for _, dpt := range dailyPrayerTimings {
+		if !r.isRamadan(dpt.Hijri) {
+			continue
+		}
+
+		var start, end time.Time
+		for _, p := range append(append([]Prayer(nil), dpt.Prayers...), dpt.Extras...) {
+			switch {
+			case p.Type == aladhan.Imsak, p.Type == aladhan.Fajr && start.IsZero():
+				start = p.Time
+			case p.Type == aladhan.Maghrib:
+				end = p.Time
+			}
+		}
+		// The fast starts at the next imsak (or fajr), unless it has already started and ends at the next maghrib
+		if !start.IsZero() && start.After(t) {
+			countdown.Next, countdown.Time = SUHOOR, &start
+		} else if !end.IsZero() && end.After(t) {
+			countdown.Fasting, countdown.Next, countdown.Time = true, IFTAR, &end
+		} else {
+			continue
+		}
+		countdown.Seconds = int64(countdown.Time.Sub(t).Seconds())
+		return countdown
+	}
+	return countdown
+}
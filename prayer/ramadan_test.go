@@ -0,0 +1,168 @@
+package prayer
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/zees-dev/prayeralarm/aladhan"
+	"github.com/zees-dev/prayeralarm/praytime"
+)
+
+func TestParseRamadanMode(t *testing.T) {
+	for _, mode := range []RamadanMode{AUTO, ON, OFF} {
+		if got, err := ParseRamadanMode(string(mode)); err != nil || got != mode {
+			t.Errorf("want %s, got %s (err=%v)", mode, got, err)
+		}
+	}
+	if _, err := ParseRamadanMode("always"); err == nil {
+		t.Error("want error, got nil")
+	}
+}
+
+func TestRamadanAlerts(t *testing.T) {
+	tomorrow := time.Now().AddDate(0, 0, 1)
+	day := func(month int) CalendarDay {
+		date := time.Date(tomorrow.Year(), tomorrow.Month(), tomorrow.Day(), 0, 0, 0, 0, time.Local)
+		return CalendarDay{
+			Date:  date,
+			Hijri: praytime.HijriDate{Day: 1, Month: praytime.HijriMonths[month-1], Year: 1442},
+			Timings: map[aladhan.Adhan]time.Time{
+				aladhan.Imsak:   date.Add(4*time.Hour + 50*time.Minute),
+				aladhan.Fajr:    date.Add(5 * time.Hour),
+				aladhan.Dhuhr:   date.Add(13 * time.Hour),
+				aladhan.Maghrib: date.Add(18 * time.Hour),
+			},
+		}
+	}
+	alertsOf := func(dpt DailyPrayerTimings, adhan aladhan.Adhan) []Alert {
+		for _, p := range dpt.Prayers {
+			if p.Type == adhan {
+				return p.Alerts
+			}
+		}
+		return nil
+	}
+
+	t.Run("test suhoor and iftar alerts are detected from the hijri month", func(t *testing.T) {
+		svc := NewService(NewStdOutPlayer(), NewPrayerDatabase(), stubProvider{}, Settings{Ramadan: Ramadan{Mode: AUTO, SuhoorLeadTime: 30}})
+		got, err := svc.generatePrayers([]CalendarDay{day(praytime.Ramadan)})
+		if err != nil {
+			t.Fatalf("unexpected error: %s", err)
+		}
+
+		imsak := day(praytime.Ramadan).Timings[aladhan.Imsak]
+		suhoor := alertsOf(got[0], aladhan.Fajr)
+		if len(suhoor) != 1 || suhoor[0].Type != SUHOOR || !suhoor[0].Time.Equal(imsak.Add(-30*time.Minute)) {
+			t.Errorf("want suhoor alert at %s, got %+v", imsak.Add(-30*time.Minute), suhoor)
+		}
+		maghrib := day(praytime.Ramadan).Timings[aladhan.Maghrib]
+		iftar := alertsOf(got[0], aladhan.Maghrib)
+		if len(iftar) != 1 || iftar[0].Type != IFTAR || !iftar[0].Time.Equal(maghrib) {
+			t.Errorf("want iftar alert at %s, got %+v", maghrib, iftar)
+		}
+		if alerts := alertsOf(got[0], aladhan.Dhuhr); len(alerts) != 0 {
+			t.Errorf("want no dhuhr alerts, got %+v", alerts)
+		}
+	})
+
+	t.Run("test iftar alert is played after the maghrib adhan", func(t *testing.T) {
+		player := &recordingPlayer{}
+		provider := stubProvider{days: []CalendarDay{day(praytime.Ramadan)}}
+		svc := NewService(player, NewPrayerDatabase(), provider, Settings{Ramadan: Ramadan{Mode: AUTO}})
+		if err := svc.loadCalendar(context.Background(), tomorrow.Year(), tomorrow.Month()); err != nil {
+			t.Fatalf("unexpected error: %s", err)
+		}
+
+		for _, event := range svc.scheduler.Events() {
+			if event.ID != prefetchEventID && event.ID != calendarEventID {
+				event.Action(context.Background())
+			}
+		}
+		var got []Cue
+		for _, cue := range player.cues {
+			if cue.Adhan == aladhan.Maghrib {
+				got = append(got, cue)
+			}
+		}
+		if len(got) != 2 || got[0].Alert != "" || got[1].Alert != IFTAR {
+			t.Errorf("want maghrib adhan followed by iftar alert, got %+v", got)
+		}
+	})
+
+	t.Run("test no alerts outside of ramadan unless forced", func(t *testing.T) {
+		for mode, want := range map[RamadanMode]int{AUTO: 0, OFF: 0, ON: 1} {
+			svc := NewService(NewStdOutPlayer(), NewPrayerDatabase(), stubProvider{}, Settings{Ramadan: Ramadan{Mode: mode}})
+			got, err := svc.generatePrayers([]CalendarDay{day(praytime.Ramadan + 1)})
+			if err != nil {
+				t.Fatalf("unexpected error: %s", err)
+			}
+			if alerts := alertsOf(got[0], aladhan.Maghrib); len(alerts) != want {
+				t.Errorf("want %d iftar alerts in %s mode, got %+v", want, mode, alerts)
+			}
+		}
+	})
+}
+
+func TestFastingCountdown(t *testing.T) {
+	date := time.Date(2021, 4, 14, 0, 0, 0, 0, time.UTC)
+	ramadan := praytime.HijriDate{Day: 2, Month: praytime.HijriMonths[praytime.Ramadan-1], Year: 1442}
+	timings := []DailyPrayerTimings{
+		{
+			Date:    date,
+			Hijri:   ramadan,
+			Prayers: []Prayer{{Type: aladhan.Fajr, Time: date.Add(5 * time.Hour)}, {Type: aladhan.Maghrib, Time: date.Add(18 * time.Hour)}},
+			Extras:  []Prayer{{Type: aladhan.Imsak, Time: date.Add(4*time.Hour + 50*time.Minute)}},
+		},
+		{
+			Date:    date.AddDate(0, 0, 1),
+			Hijri:   praytime.HijriDate{Day: 3, Month: ramadan.Month, Year: 1442},
+			Prayers: []Prayer{{Type: aladhan.Fajr, Time: date.Add(29 * time.Hour)}, {Type: aladhan.Maghrib, Time: date.Add(42 * time.Hour)}},
+		},
+	}
+	settings := Ramadan{Mode: AUTO}
+
+	t.Run("test countdown to end of suhoor", func(t *testing.T) {
+		got := settings.fastingCountdown(timings, date.Add(4*time.Hour))
+		if !got.Ramadan || got.Fasting || got.Next != SUHOOR || got.Seconds != 50*60 {
+			t.Errorf("want suhoor in 50 mins, got %+v", got)
+		}
+	})
+
+	t.Run("test countdown to iftar", func(t *testing.T) {
+		got := settings.fastingCountdown(timings, date.Add(12*time.Hour))
+		if !got.Fasting || got.Next != IFTAR || got.Seconds != 6*60*60 {
+			t.Errorf("want iftar in 6 hours, got %+v", got)
+		}
+	})
+
+	t.Run("test countdown to suhoor of the next day after iftar", func(t *testing.T) {
+		got := settings.fastingCountdown(timings, date.Add(20*time.Hour))
+		if got.Fasting || got.Next != SUHOOR || !got.Time.Equal(date.Add(29*time.Hour)) {
+			t.Errorf("want suhoor at %s, got %+v", date.Add(29*time.Hour), got)
+		}
+	})
+
+	t.Run("test ramadan is detected from the day of t", func(t *testing.T) {
+		shaban := praytime.HijriDate{Day: 29, Month: praytime.HijriMonths[praytime.Ramadan-2], Year: 1442}
+		beforeRamadan := append([]DailyPrayerTimings{{
+			Date:    date.AddDate(0, 0, -1),
+			Hijri:   shaban,
+			Prayers: []Prayer{{Type: aladhan.Fajr, Time: date.Add(-19 * time.Hour)}, {Type: aladhan.Maghrib, Time: date.Add(-6 * time.Hour)}},
+		}}, timings...)
+
+		if got := settings.fastingCountdown(beforeRamadan, date.Add(-12*time.Hour)); got.Ramadan {
+			t.Errorf("want no ramadan on the last day of shaban, got %+v", got)
+		}
+		if got := settings.fastingCountdown(beforeRamadan, date.Add(12*time.Hour)); !got.Ramadan || !got.Fasting {
+			t.Errorf("want fasting during ramadan, got %+v", got)
+		}
+	})
+
+	t.Run("test no countdown outside of ramadan", func(t *testing.T) {
+		got := Ramadan{Mode: OFF}.fastingCountdown(timings, date)
+		if got.Ramadan || got.Next != "" || got.Time != nil {
+			t.Errorf("want no countdown, got %+v", got)
+		}
+	})
+}
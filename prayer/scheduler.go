@@ -0,0 +1,221 @@
+package prayer
+
+import (
+	"container/heap"
+	"context"
+	"log"
+	"sort"
+	"sync"
+	"time"
+)
+
+// Event is an action scheduled to run at a point in time; events are identified by their ID.
+// Events due at the same time are run in ascending order of their priority.
+type Event struct {
+	ID       string                    `json:"id"`
+	Time     time.Time                 `json:"time"`
+	Priority int                       `json:"priority"`
+	Action   func(ctx context.Context) `json:"-"`
+}
+
+// before reports whether the event is run before the other event
+func (e Event) before(other Event) bool {
+	if e.Time.Equal(other.Time) {
+		return e.Priority < other.Priority
+	}
+	return e.Time.Before(other.Time)
+}
+
+// eventHeap is a min-heap of events ordered by time and priority
+// https://golang.org/pkg/container/heap/#example__priorityQueue
+type eventHeap []*scheduledEvent
+
+type scheduledEvent struct {
+	Event
+	index int
+}
+
+func (h eventHeap) Len() int { return len(h) }
+
+func (h eventHeap) Less(i, j int) bool { return h[i].before(h[j].Event) }
+
+func (h eventHeap) Swap(i, j int) {
+	h[i], h[j] = h[j], h[i]
+	h[i].index = i
+	h[j].index = j
+}
+
+func (h *eventHeap) Push(x interface{}) {
+	e := x.(*scheduledEvent)
+	e.index = len(*h)
+	*h = append(*h, e)
+}
+
+func (h *eventHeap) Pop() interface{} {
+	old := *h
+	n := len(old)
+	e := old[n-1]
+	old[n-1] = nil
+	e.index = -1
+	*h = old[:n-1]
+	return e
+}
+
+// Scheduler runs the actions of events at their scheduled time, in time (and priority) order.
+// Events can be added, removed and rescheduled while the scheduler is running.
+type Scheduler struct {
+	mutex  sync.Mutex
+	events eventHeap
+	byID   map[string]*scheduledEvent
+	wake   chan struct{}
+}
+
+// NewScheduler returns a scheduler without any events
+func NewScheduler() *Scheduler {
+	return &Scheduler{
+		byID: make(map[string]*scheduledEvent),
+		wake: make(chan struct{}, 1),
+	}
+}
+
+// Add schedules the event, replacing any scheduled event with the same ID;
+// events scheduled in the past are run immediately
+func (s *Scheduler) Add(event Event) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	if e, ok := s.byID[event.ID]; ok {
+		e.Event = event
+		heap.Fix(&s.events, e.index)
+	} else {
+		e := &scheduledEvent{Event: event}
+		heap.Push(&s.events, e)
+		s.byID[event.ID] = e
+	}
+	s.notify()
+}
+
+// Remove unschedules the event with the ID, reporting whether it was scheduled
+func (s *Scheduler) Remove(id string) bool {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	e, ok := s.byID[id]
+	if !ok {
+		return false
+	}
+	heap.Remove(&s.events, e.index)
+	delete(s.byID, id)
+	s.notify()
+	return true
+}
+
+// Reschedule moves the event with the ID to run at t, reporting whether it was scheduled
+func (s *Scheduler) Reschedule(id string, t time.Time) bool {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	e, ok := s.byID[id]
+	if !ok {
+		return false
+	}
+	e.Time = t
+	heap.Fix(&s.events, e.index)
+	s.notify()
+	return true
+}
+
+// Next returns the next event to be run, which the scheduler timer is armed for
+func (s *Scheduler) Next() (Event, bool) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	if len(s.events) == 0 {
+		return Event{}, false
+	}
+	return s.events[0].Event, true
+}
+
+// Events returns the scheduled events in time (and priority) order
+func (s *Scheduler) Events() []Event {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	events := make([]Event, 0, len(s.events))
+	for _, e := range s.events {
+		events = append(events, e.Event)
+	}
+	sortEvents(events)
+	return events
+}
+
+// Run waits for each event and runs its action, until the context is done.
+// Actions are run sequentially on the calling goroutine; events which become due while an
+// action is running are run once it returns. An in-flight action is not interrupted when the
+// context is done, but no further actions are run.
+func (s *Scheduler) Run(ctx context.Context) error {
+	var armed Event
+	for {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+
+		s.mutex.Lock()
+		var timer *time.Timer
+		var timerC <-chan time.Time
+		if len(s.events) > 0 {
+			next := s.events[0].Event
+			if next.ID != armed.ID || !next.Time.Equal(armed.Time) {
+				log.Printf("next event %s at %s, waiting %s...", next.ID, next.Time, time.Until(next.Time).Round(time.Second))
+				armed = next
+			}
+			timer = time.NewTimer(time.Until(next.Time))
+			timerC = timer.C
+		}
+		s.mutex.Unlock()
+
+		select {
+		case <-ctx.Done():
+			if timer != nil {
+				timer.Stop()
+			}
+			return ctx.Err()
+		case <-s.wake:
+			if timer != nil {
+				timer.Stop()
+			}
+		case <-timerC:
+			if event, ok := s.pop(time.Now()); ok {
+				event.Action(ctx)
+			}
+		}
+	}
+}
+
+// pop removes and returns the next event if it is due at t
+func (s *Scheduler) pop(t time.Time) (Event, bool) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	if len(s.events) == 0 || s.events[0].Time.After(t) {
+		return Event{}, false
+	}
+	e := heap.Pop(&s.events).(*scheduledEvent)
+	delete(s.byID, e.ID)
+	return e.Event, true
+}
+
+// sortEvents sorts events by time and priority
+func sortEvents(events []Event) {
+	sort.Slice(events, func(i, j int) bool {
+		return events[i].before(events[j])
+	})
+}
+
+// notify wakes the running scheduler to re-arm its timer
+func (s *Scheduler) notify() {
+	select {
+	case s.wake <- struct{}{}:
+	default:
+	}
+}
@@ -0,0 +1,146 @@
+package prayer
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+// runScheduler runs the scheduler until the test completes
+func runScheduler(t *testing.T, s *Scheduler) {
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan error)
+	go func() { done <- s.Run(ctx) }()
+	t.Cleanup(func() {
+		cancel()
+		<-done
+	})
+}
+
+// recordEvent returns an event which sends its ID to fired when run
+func recordEvent(id string, t time.Time, fired chan<- string) Event {
+	return Event{ID: id, Time: t, Action: func(ctx context.Context) { fired <- id }}
+}
+
+func receiveEvent(t *testing.T, fired <-chan string) string {
+	select {
+	case id := <-fired:
+		return id
+	case <-time.After(time.Second):
+		t.Fatal("want event to be run, got timeout")
+		return ""
+	}
+}
+
+func TestScheduler(t *testing.T) {
+	t.Run("test events are run in time order", func(t *testing.T) {
+		s := NewScheduler()
+		fired := make(chan string, 3)
+		now := time.Now()
+		s.Add(recordEvent("c", now.Add(60*time.Millisecond), fired))
+		s.Add(recordEvent("a", now.Add(20*time.Millisecond), fired))
+		s.Add(recordEvent("b", now.Add(40*time.Millisecond), fired))
+		runScheduler(t, s)
+
+		for _, want := range []string{"a", "b", "c"} {
+			if got := receiveEvent(t, fired); got != want {
+				t.Errorf("want %s, got %s", want, got)
+			}
+		}
+		if _, ok := s.Next(); ok {
+			t.Error("want no events after run, got event")
+		}
+	})
+
+	t.Run("test events due at the same time are run in priority order", func(t *testing.T) {
+		s := NewScheduler()
+		fired := make(chan string, 3)
+		at := time.Now().Add(20 * time.Millisecond)
+		for _, event := range []Event{recordEvent("c", at, fired), recordEvent("a", at, fired), recordEvent("b", at, fired)} {
+			event.Priority = int(event.ID[0] - 'a')
+			s.Add(event)
+		}
+		runScheduler(t, s)
+
+		for _, want := range []string{"a", "b", "c"} {
+			if got := receiveEvent(t, fired); got != want {
+				t.Errorf("want %s, got %s", want, got)
+			}
+		}
+	})
+
+	t.Run("test events added while running are run", func(t *testing.T) {
+		s := NewScheduler()
+		fired := make(chan string, 2)
+		s.Add(recordEvent("later", time.Now().Add(time.Hour), fired))
+		runScheduler(t, s)
+
+		s.Add(recordEvent("now", time.Now().Add(10*time.Millisecond), fired))
+		if got := receiveEvent(t, fired); got != "now" {
+			t.Errorf("want %s, got %s", "now", got)
+		}
+	})
+
+	t.Run("test removed events are not run", func(t *testing.T) {
+		s := NewScheduler()
+		fired := make(chan string, 2)
+		now := time.Now()
+		s.Add(recordEvent("removed", now.Add(20*time.Millisecond), fired))
+		s.Add(recordEvent("kept", now.Add(40*time.Millisecond), fired))
+		runScheduler(t, s)
+
+		if !s.Remove("removed") {
+			t.Error("want removed event to be scheduled, got unscheduled")
+		}
+		if got := receiveEvent(t, fired); got != "kept" {
+			t.Errorf("want %s, got %s", "kept", got)
+		}
+		if s.Remove("removed") {
+			t.Error("want removed event to be unscheduled, got scheduled")
+		}
+	})
+
+	t.Run("test rescheduled events are run at their new time", func(t *testing.T) {
+		s := NewScheduler()
+		fired := make(chan string, 2)
+		now := time.Now()
+		s.Add(recordEvent("first", now.Add(40*time.Millisecond), fired))
+		s.Add(recordEvent("second", now.Add(time.Hour), fired))
+		runScheduler(t, s)
+
+		s.Reschedule("second", now.Add(20*time.Millisecond))
+		if got := receiveEvent(t, fired); got != "second" {
+			t.Errorf("want %s, got %s", "second", got)
+		}
+		if got := receiveEvent(t, fired); got != "first" {
+			t.Errorf("want %s, got %s", "first", got)
+		}
+	})
+
+	t.Run("test next returns earliest event and adding replaces by id", func(t *testing.T) {
+		s := NewScheduler()
+		now := time.Now()
+		s.Add(Event{ID: "a", Time: now.Add(2 * time.Hour)})
+		s.Add(Event{ID: "b", Time: now.Add(3 * time.Hour)})
+		s.Add(Event{ID: "b", Time: now.Add(time.Hour)})
+
+		next, ok := s.Next()
+		if !ok || next.ID != "b" || !next.Time.Equal(now.Add(time.Hour)) {
+			t.Errorf("want %s at %s, got %+v", "b", now.Add(time.Hour), next)
+		}
+		if got := len(s.Events()); got != 2 {
+			t.Errorf("want %d events, got %d", 2, got)
+		}
+	})
+
+	t.Run("test run returns when context is done", func(t *testing.T) {
+		s := NewScheduler()
+		s.Add(Event{ID: "later", Time: time.Now().Add(time.Hour)})
+		ctx, cancel := context.WithCancel(context.Background())
+		cancel()
+
+		if err := s.Run(ctx); err != context.Canceled {
+			t.Errorf("want %s, got %v", context.Canceled, err)
+		}
+	})
+}
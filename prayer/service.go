@@ -9,85 +9,423 @@ import (
 	"os"
 	"sort"
 	"strconv"
+	"strings"
 	"sync"
 	"time"
 
 	"github.com/olekukonko/tablewriter"
 	"github.com/zees-dev/prayeralarm/aladhan"
-	"golang.org/x/sync/errgroup"
+	"github.com/zees-dev/prayeralarm/praytime"
 )
 
 type PrayerService interface {
+	GetSettings() Settings
+	GetLocation() Location
 	GetPrayerTimings() []DailyPrayerTimings
 	DisplayPrayerTimings(writer io.Writer, dailyPrayerTimings []DailyPrayerTimings)
-	ToggleAdhan(index int) (*Prayer, error)
+	ToggleAdhan(id string) (*Prayer, error)
+	ToggleAdhanByIndex(index int) (*Prayer, error)
+	ToggleAlert(id string) (*Alert, error)
 	TurnOffAllAdhan()
 	TurnOnAllAdhan()
+	NextEvent() (Event, bool)
+	StopPlayback() (*Cue, error)
+	GetPlaybackPolicy() PlaybackPolicy
+	SetPlaybackPolicy(policy PlaybackPolicy) error
+	GetFastingCountdown() FastingCountdown
+	Shutdown(ctx context.Context) error
 }
 
 var ErrNoPrayerCall = errors.New("no prayer calls exist prior to current time")
 
+// ErrNoPlayback is returned when stopping playback while no cue is being played
+var ErrNoPlayback = errors.New("no playback in progress")
+
+const (
+	// calendarRetryInterval is the wait period before re-attempting to retrieve a monthly calendar
+	calendarRetryInterval = time.Minute
+	// calendarPrefetchPeriod is the period prior to the next month at which its calendar is prefetched
+	calendarPrefetchPeriod = 3 * 24 * time.Hour
+)
+
+// Scheduler event IDs of the monthly calendar load and prefetch
+const (
+	calendarEventID = "calendar"
+	prefetchEventID = "prefetch"
+)
+
+// Prayer is an adhan (or non-adhan) timing; identified by its ID, which is stable across restarts
 type Prayer struct {
-	Play  bool          `json:"play"`
-	Type  aladhan.Adhan `json:"type"`
-	Time  time.Time     `json:"time"`
-	Index uint8         `json:"index"`
+	ID     string        `json:"id"`
+	Play   bool          `json:"play"`
+	Type   aladhan.Adhan `json:"type"`
+	Time   time.Time     `json:"time"`
+	Alerts []Alert       `json:"alerts"`
+	// Deprecated: Index is the position of the adhan within the month of the service start; use ID
+	Index uint8 `json:"index"`
+}
+
+// prayerIDDateFormat is the date format of prayer IDs
+const prayerIDDateFormat = "2006-01-02"
+
+// prayerID identifies the timing by the date of its calendar day and its type; i.e. `2021-01-01-fajr`.
+// Timings past midnight (i.e. isha) are identified by the date of their calendar day, not their own date.
+func prayerID(adhan aladhan.Adhan, t time.Time) string {
+	return fmt.Sprintf("%s-%s", t.Format(prayerIDDateFormat), strings.ToLower(string(adhan)))
+}
+
+// obligatoryAdhans are the 5 daily adhans, in order of the day
+var obligatoryAdhans = []aladhan.Adhan{aladhan.Fajr, aladhan.Dhuhr, aladhan.Asr, aladhan.Maghrib, aladhan.Isha}
+
+// parseAdhanInts parses the comma separated integers of the 5 daily adhans (see `obligatoryAdhans`)
+func parseAdhanInts(values string) (map[aladhan.Adhan]int, error) {
+	valueSlice := strings.Split(values, ",")
+	if len(valueSlice) != len(obligatoryAdhans) {
+		return nil, fmt.Errorf("%d comma separated integers are required", len(obligatoryAdhans))
+	}
+
+	parsed := make(map[aladhan.Adhan]int, len(obligatoryAdhans))
+	for i, adhan := range obligatoryAdhans {
+		value, err := strconv.Atoi(strings.TrimSpace(valueSlice[i]))
+		if err != nil {
+			return nil, fmt.Errorf(`invalid integer "%s" for %s`, strings.TrimSpace(valueSlice[i]), adhan)
+		}
+		parsed[adhan] = value
+	}
+	return parsed, nil
 }
 
+// DailyPrayerTimings holds the adhans of a day; along with the non-adhan timings (imsak, sunrise, sunset
+// and midnight) in `Extras`, which are not played; other than the tahajjud alarm of the following night
 type DailyPrayerTimings struct {
-	Date    time.Time `json:"date"`
-	Prayers []Prayer  `json:"prayers"`
+	Date    time.Time          `json:"date"`
+	Hijri   praytime.HijriDate `json:"hijri"`
+	Prayers []Prayer           `json:"prayers"`
+	Extras  []Prayer           `json:"extras"`
 }
 
 // type DailyPrayerTimings map[uint8][]Prayer
 
+// Settings holds the location and calculation settings of the adhan timings;
+// HijriAdjustment is the number of days to adjust hijri dates by, for local moon sighting
+type Settings struct {
+	Location        Location        `json:"location"`
+	Calculation     praytime.Params `json:"calculation"`
+	Offsets         string          `json:"offsets"`
+	HijriAdjustment int             `json:"hijriAdjustment"`
+	Reminders       Reminders       `json:"reminders"`
+	Iqamah          Iqamah          `json:"iqamah"`
+	Jumuah          Jumuah          `json:"jumuah"`
+	Ramadan         Ramadan         `json:"ramadan"`
+	Tahajjud        TahajjudMode    `json:"tahajjud"`
+	Playback        PlaybackPolicy  `json:"playback"`
+}
+
 type Service struct {
-	mutex          sync.RWMutex
-	player         Player
-	prayerDatabase PrayerDatabase
+	mutex            sync.RWMutex
+	player           Player
+	prayerDatabase   PrayerDatabase
+	calendarProvider CalendarProvider
+	settings         Settings
+	location         Location
+	scheduler        *Scheduler
+	stopped          chan struct{}
+	playbackMutex    sync.Mutex
+	playback         *playback
+}
+
+// playback is the in-flight playback of a cue
+type playback struct {
+	cue    Cue
+	cancel context.CancelFunc
 }
 
 // NewService returns new adhan service that utilizes player to output adhan audio
-func NewService(player Player, prayerDatabase PrayerDatabase) *Service {
+// and retrieves monthly adhan timings for the settings from the calendar provider
+func NewService(player Player, prayerDatabase PrayerDatabase, calendarProvider CalendarProvider, settings Settings) *Service {
 	return &Service{
-		player:         player,
-		prayerDatabase: prayerDatabase,
+		player:           player,
+		prayerDatabase:   prayerDatabase,
+		calendarProvider: calendarProvider,
+		settings:         settings,
+		location:         settings.Location,
+		scheduler:        NewScheduler(),
+		stopped:          make(chan struct{}),
 	}
 }
 
 // InitialisePrayeralarm will initialise the service to run monthly prayer calls.
-// The service will populate the prayer adhan timings on a monthly basis, then loop
-// through all the prayers of the month (incrementally) to play the adhan at the specified
-// prayer time to the provided player.
-func (svc *Service) InitialisePrayeralarm(year int, month time.Month, city, country, offset string) {
+// The service will populate the prayer adhan timings on a monthly basis and schedule the adhan
+// of each prayer to be played at the specified prayer time to the provided player; the calendar of
+// the following month is loaded when the month rolls over.
+// Failures to retrieve the monthly calendar are logged and retried after `calendarRetryInterval`.
+// Scheduled events are run until the context is done; see `Shutdown`.
+func (svc *Service) InitialisePrayeralarm(ctx context.Context, year int, month time.Month) {
+	defer close(svc.stopped)
+
 	log.Println("running prayeralarm service...")
-	for {
-		// create prayer timings channel for max possible prayers in a month
-		prayerCh := make(chan Prayer, 31*5)
+	svc.scheduleCalendar(year, month, time.Now())
+	if err := svc.scheduler.Run(ctx); err != nil {
+		log.Printf("prayeralarm service stopped: %s", err)
+	}
+}
 
-		monthCalendar := aladhan.GetMonthCalendar(city, country, offset, month, year)
+// Shutdown waits for the service to stop running scheduled events (once the context passed to
+// `InitialisePrayeralarm` is done), letting in-flight adhan playback finish until ctx is done;
+// after which playback is stopped. The player and prayer database are closed if they hold resources.
+func (svc *Service) Shutdown(ctx context.Context) error {
+	select {
+	case <-svc.stopped:
+	case <-ctx.Done():
+		log.Printf("stopping in-flight playback: %s", ctx.Err())
+		svc.StopPlayback()
+	}
 
-		dailyPrayerTimings, err := svc.generatePrayers(monthCalendar)
-		if err != nil {
-			log.Fatalf("error generating prayer timings: %s", err.Error())
+	var err error
+	if closer, ok := svc.player.(io.Closer); ok {
+		if closeErr := closer.Close(); closeErr != nil {
+			err = fmt.Errorf("error closing player: %w", closeErr)
 		}
-		svc.prayerDatabase.SetTimings(dailyPrayerTimings)
+	}
+	if closer, ok := svc.prayerDatabase.(io.Closer); ok {
+		if closeErr := closer.Close(); closeErr != nil && err == nil {
+			err = fmt.Errorf("error closing prayer database: %w", closeErr)
+		}
+	}
+	return err
+}
 
-		svc.DisplayPrayerTimings(os.Stdout, dailyPrayerTimings)
+// scheduleCalendar schedules the calendar of the month to be loaded at t
+func (svc *Service) scheduleCalendar(year int, month time.Month, t time.Time) {
+	svc.scheduler.Add(Event{ID: calendarEventID, Time: t, Action: func(ctx context.Context) {
+		if err := svc.loadCalendar(ctx, year, month); err != nil {
+			log.Printf("error loading calendar for %s %d, retrying in %s: %s", month, year, calendarRetryInterval, err)
+			svc.scheduleCalendar(year, month, time.Now().Add(calendarRetryInterval))
+			return
+		}
+		nextMonth := time.Date(year, month+1, 1, 0, 0, 0, 0, time.Local)
+		svc.scheduleCalendar(nextMonth.Year(), nextMonth.Month(), nextMonth)
+	}})
+}
 
-		svc.populatePrayerTimings(prayerCh, dailyPrayerTimings)
+// loadCalendar retrieves the calendar of the month, stores its prayer timings and schedules the adhans and alerts
+func (svc *Service) loadCalendar(ctx context.Context, year int, month time.Month) error {
+	monthCalendar, err := svc.calendarProvider.MonthCalendar(ctx, svc.settings.Location, year, month)
+	if err != nil {
+		return err
+	}
 
-		err = svc.playPrayerCalls(prayerCh)
-		if err != nil {
-			log.Fatalf("error playing prayer calls: %s", err.Error())
+	previousTimings := svc.prayerDatabase.Timings()
+	dailyPrayerTimings, err := svc.generatePrayers(monthCalendar.Days)
+	if err != nil {
+		return err
+	}
+	// The trailing night of the previous month (i.e. tahajjud and isha past midnight) is carried over, so that its
+	// scheduled timings remain listed and can be toggled; adhans are re-indexed in time order
+	if len(monthCalendar.Days) > 0 {
+		if night := trailingNight(previousTimings, monthCalendar.Days[0].Date, time.Now()); len(night) > 0 {
+			dailyPrayerTimings = append(night, dailyPrayerTimings...)
+			prayerIndex := uint8(0)
+			for _, dpt := range dailyPrayerTimings {
+				for i := range dpt.Prayers {
+					dpt.Prayers[i].Index = prayerIndex
+					prayerIndex++
+				}
+			}
+		}
+	}
+	svc.setLocation(monthCalendar.Location)
+	// Persisted settings (i.e. muted adhans) are applied to the prayer timings by the database
+	if err := svc.prayerDatabase.SetTimings(dailyPrayerTimings); err != nil {
+		return err
+	}
+	dailyPrayerTimings = svc.prayerDatabase.Timings()
+
+	svc.DisplayPrayerTimings(os.Stdout, dailyPrayerTimings)
+
+	for _, dpt := range dailyPrayerTimings {
+		for _, p := range dpt.Prayers {
+			svc.schedulePrayer(p)
+		}
+		for _, p := range dpt.Extras {
+			svc.schedulePrayer(p)
+		}
+	}
+	svc.schedulePrefetch(year, month)
+	return nil
+}
+
+// trailingNight returns the days of the prayer timings prior to the date which have upcoming timings at the current
+// time (i.e. the last night of the previous month); along with the upcoming prayers (or those with upcoming alerts)
+// and non-adhan timings of those days
+func trailingNight(prayerTimings []DailyPrayerTimings, date time.Time, currentTime time.Time) []DailyPrayerTimings {
+	upcoming := func(p Prayer) bool {
+		if p.Time.After(currentTime) {
+			return true
+		}
+		for _, alert := range p.Alerts {
+			if alert.Time.After(currentTime) {
+				return true
+			}
+		}
+		return false
+	}
+
+	night := make([]DailyPrayerTimings, 0)
+	for _, dpt := range prayerTimings {
+		if !dpt.Date.Before(date) {
+			continue
+		}
+		day := DailyPrayerTimings{Date: dpt.Date, Hijri: dpt.Hijri, Prayers: make([]Prayer, 0), Extras: make([]Prayer, 0)}
+		for _, p := range dpt.Prayers {
+			if upcoming(p) {
+				p.Alerts = append([]Alert(nil), p.Alerts...)
+				day.Prayers = append(day.Prayers, p)
+			}
+		}
+		for _, p := range dpt.Extras {
+			if upcoming(p) {
+				day.Extras = append(day.Extras, p)
+			}
 		}
+		if len(day.Prayers) > 0 || len(day.Extras) > 0 {
+			night = append(night, day)
+		}
+	}
+	return night
+}
+
+// schedulePrefetch schedules the calendar of the month after (year, month) to be retrieved ahead of time,
+// so that calendar providers can cache it prior to the month rollover
+func (svc *Service) schedulePrefetch(year int, month time.Month) {
+	nextMonth := time.Date(year, month+1, 1, 0, 0, 0, 0, time.Local)
+	nextYear, nextMonthNum := nextMonth.Year(), nextMonth.Month()
+
+	svc.scheduler.Add(Event{ID: prefetchEventID, Time: nextMonth.Add(-calendarPrefetchPeriod), Action: func(ctx context.Context) {
+		// Retrieved in the background so that adhan playback is not delayed by API retries
+		go func() {
+			log.Printf("prefetching calendar for %s %d...", nextMonthNum, nextYear)
+			if _, err := svc.calendarProvider.MonthCalendar(ctx, svc.settings.Location, nextYear, nextMonthNum); err != nil {
+				log.Printf("error prefetching calendar for %s %d: %s", nextMonthNum, nextYear, err)
+			}
+		}()
+	}})
+}
+
+// adhanEventID identifies the scheduler event of the prayer adhan
+func adhanEventID(p Prayer) string {
+	return fmt.Sprintf("adhan-%s", p.ID)
+}
+
+// alertEventID identifies the scheduler event of the prayer alert
+func alertEventID(alert Alert) string {
+	return fmt.Sprintf("alert-%s", alert.ID)
+}
+
+// schedulePrayer schedules (or unschedules) the adhan and alerts of the prayer according to whether they are set to play
+func (svc *Service) schedulePrayer(p Prayer) {
+	svc.scheduleCue(adhanEventID(p), p.Play, p.Time, Cue{Adhan: p.Type})
+	for _, alert := range p.Alerts {
+		svc.scheduleCue(alertEventID(alert), alert.Play, alert.Time, Cue{Adhan: p.Type, Alert: alert.Type})
+	}
+}
+
+// scheduleCue schedules the cue to be played at t if it is set to play and upcoming; otherwise it is unscheduled.
+// The playback mode and volume of the cue are determined by the playback policy when it is played.
+func (svc *Service) scheduleCue(eventID string, play bool, t time.Time, cue Cue) {
+	if !play || !t.After(time.Now()) {
+		svc.scheduler.Remove(eventID)
+		return
+	}
+	svc.scheduler.Add(Event{ID: eventID, Time: t, Priority: cue.priority(), Action: func(ctx context.Context) {
+		cue := svc.playbackPolicy().apply(cue, t)
+		if cue.Mode == MUTE {
+			log.Printf("Skipping %s at %s; muted by playback policy", cue, t)
+			return
+		}
+		log.Printf("Playing %s at %s...", cue, t)
+		err := svc.play(cue)
+		switch {
+		case errors.Is(err, context.Canceled):
+			log.Printf("stopped playing %s", cue)
+		case errors.Is(err, context.DeadlineExceeded):
+			log.Printf("stopped playing %s; exceeded playback timeout of %s", cue, svc.playbackPolicy().Timeout)
+		case err != nil:
+			log.Printf("error playing %s: %s", cue, err)
+		}
+	}})
+}
+
+// play plays the cue until it finishes, is stopped (see `StopPlayback`) or exceeds the playback timeout.
+// Playback is not bound to the scheduler context, so that in-flight playback can finish on shutdown.
+func (svc *Service) play(cue Cue) error {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	if timeout := svc.playbackPolicy().Timeout; timeout > 0 {
+		var cancelTimeout context.CancelFunc
+		ctx, cancelTimeout = context.WithTimeout(ctx, timeout)
+		defer cancelTimeout()
+	}
+
+	svc.playbackMutex.Lock()
+	svc.playback = &playback{cue: cue, cancel: cancel}
+	svc.playbackMutex.Unlock()
+	defer func() {
+		svc.playbackMutex.Lock()
+		svc.playback = nil
+		svc.playbackMutex.Unlock()
+	}()
+
+	return svc.player.Play(ctx, cue)
+}
+
+// StopPlayback stops the in-flight playback, returning the cue which was being played
+func (svc *Service) StopPlayback() (*Cue, error) {
+	svc.playbackMutex.Lock()
+	defer svc.playbackMutex.Unlock()
+
+	if svc.playback == nil {
+		return nil, ErrNoPlayback
+	}
+	svc.playback.cancel()
+	cue := svc.playback.cue
+	return &cue, nil
+}
+
+// GetPlaybackPolicy returns a copy of the playback policy, which may be modified and set via `SetPlaybackPolicy`
+func (svc *Service) GetPlaybackPolicy() PlaybackPolicy {
+	return svc.playbackPolicy().clone()
+}
 
-		year, month, _ = time.Now().AddDate(0, 1, 0).Date()
+// SetPlaybackPolicy replaces the playback policy (i.e. volumes and fades), which applies to subsequently played cues
+func (svc *Service) SetPlaybackPolicy(policy PlaybackPolicy) error {
+	if err := policy.Validate(); err != nil {
+		return err
 	}
+	svc.mutex.Lock()
+	defer svc.mutex.Unlock()
+	svc.settings.Playback = policy.clone()
+	return nil
+}
+
+func (svc *Service) playbackPolicy() PlaybackPolicy {
+	svc.mutex.RLock()
+	defer svc.mutex.RUnlock()
+	return svc.settings.Playback
+}
+
+// GetFastingCountdown returns the countdown to the next start (end of suhoor) or end (iftar) of the fast during Ramadan
+func (svc *Service) GetFastingCountdown() FastingCountdown {
+	return svc.settings.Ramadan.fastingCountdown(svc.prayerDatabase.Timings(), time.Now())
+}
+
+// NextEvent returns the next scheduled event; i.e. the next adhan to be played
+func (svc *Service) NextEvent() (Event, bool) {
+	return svc.scheduler.Next()
 }
 
-// generatePrayers extracts the monthly adhan timings from the calendar api response
-func (svc *Service) generatePrayers(monthCalendar aladhan.MonthlyAdhanCalenderResponse) ([]DailyPrayerTimings, error) {
+// generatePrayers extracts the monthly adhan timings from the provided calendar
+func (svc *Service) generatePrayers(monthCalendar []CalendarDay) ([]DailyPrayerTimings, error) {
 	svc.mutex.Lock()
 	defer svc.mutex.Unlock()
 
@@ -96,116 +434,159 @@ func (svc *Service) generatePrayers(monthCalendar aladhan.MonthlyAdhanCalenderRe
 	// Get all adhan timings after current time for remaining days of the month
 	currentTime := time.Now()
 	prayerIndex := uint8(0)
-	for _, timings := range monthCalendar.Data {
+	for i, day := range monthCalendar {
 		dailyPrayers := make([]Prayer, 0)
-		for adhan, timeStr := range timings.Timings {
-			fullTimeStr := fmt.Sprintf("%s %s", timings.Date.Readable, timeStr)
-			adhanTime := getTime(fullTimeStr, timings.Meta.Timezone)
-			if adhanTime.After(currentTime) {
-				prayer := Prayer{Play: true, Type: adhan, Time: adhanTime, Index: prayerIndex}
-				dailyPrayers = append(dailyPrayers, prayer)
-				prayerIndex++
+		extras := make([]Prayer, 0)
+		for adhan, adhanTime := range day.Timings {
+			// Jumuah takes the Dhuhr slot on Fridays; the regular Dhuhr adhan is played unless suppressed,
+			// or replaced by the jumuah adhan at the Dhuhr time
+			if adhan == aladhan.Dhuhr {
+				if jumuah, ok := svc.settings.Jumuah.jumuahPrayer(adhanTime); ok {
+					if jumuah.Time.After(currentTime) {
+						dailyPrayers = append(dailyPrayers, jumuah)
+					}
+					if svc.settings.Jumuah.SuppressDhuhr || jumuah.Time.Equal(adhanTime) {
+						continue
+					}
+				}
 			}
-		}
-		if len(dailyPrayers) > 0 {
-			dateTime, err := getDateFromTimestamp(timings.Date.Timestamp)
-			if err != nil {
-				return nil, err
+			if !adhanTime.After(currentTime) {
+				continue
+			}
+			if !adhan.IsPrayer() {
+				extras = append(extras, Prayer{ID: prayerID(adhan, day.Date), Play: false, Type: adhan, Time: adhanTime})
+				continue
 			}
+			dailyPrayers = append(dailyPrayers, Prayer{ID: prayerID(adhan, day.Date), Play: true, Type: adhan, Time: adhanTime})
+		}
+		// The tahajjud of the night spans to fajr of the next calendar day
+		var nextDay *CalendarDay
+		if i+1 < len(monthCalendar) {
+			nextDay = &monthCalendar[i+1]
+		}
+		if tahajjud, ok := svc.settings.Tahajjud.tahajjudPrayer(day, nextDay); ok && tahajjud.Time.After(currentTime) {
+			extras = append(extras, tahajjud)
+		}
+		// Sort upcoming daily adhans and extras by time, indexing adhans in time order
+		sortPrayers(dailyPrayers)
+		sortPrayers(extras)
+		for i := range dailyPrayers {
+			dailyPrayers[i].Index = prayerIndex
+			dailyPrayers[i].Alerts = svc.prayerAlerts(dailyPrayers[i], day, currentTime)
+			prayerIndex++
+		}
+		if len(dailyPrayers) > 0 || len(extras) > 0 {
 			dailyPrayerTimings = append(dailyPrayerTimings, DailyPrayerTimings{
-				Date:    dateTime,
+				Date:    day.Date,
+				Hijri:   day.Hijri,
 				Prayers: dailyPrayers,
+				Extras:  extras,
 			})
 		}
 	}
 
-	for _, dpt := range dailyPrayerTimings {
-		// Sort upcoming daily adhans by time
-		sort.Slice(dpt.Prayers[:], func(i, j int) bool {
-			return dpt.Prayers[i].Time.Before(dpt.Prayers[j].Time)
-		})
-	}
-
 	return dailyPrayerTimings, nil
 }
 
+// prayerAlerts returns the upcoming alerts (i.e. reminder and iqamah) of the prayer on the calendar day;
+// along with the suhoor (of fajr) and iftar (of maghrib) alerts during Ramadan.
+// Jumuah alerts follow the Dhuhr reminder and iqamah settings
+func (svc *Service) prayerAlerts(p Prayer, day CalendarDay, currentTime time.Time) []Alert {
+	settingsPrayer := p
+	settingsPrayer.Type = settingsAdhan(p.Type)
+
+	alerts := make([]Alert, 0)
+	if leadTime := svc.settings.Reminders[settingsPrayer.Type]; leadTime > 0 {
+		reminderTime := p.Time.Add(-time.Duration(leadTime) * time.Minute)
+		if reminderTime.After(currentTime) {
+			alerts = append(alerts, Alert{ID: alertID(p, REMINDER), Type: REMINDER, Time: reminderTime, Play: true})
+		}
+	}
+	if iqamahTime, ok := svc.settings.Iqamah.iqamahTime(settingsPrayer, day.Date); ok && iqamahTime.After(currentTime) {
+		alerts = append(alerts, Alert{ID: alertID(p, IQAMAH), Type: IQAMAH, Time: iqamahTime, Play: true})
+	}
+	if svc.settings.Ramadan.isRamadan(day.Hijri) {
+		switch p.Type {
+		case aladhan.Fajr:
+			if start, ok := fastStart(day); ok {
+				suhoorTime := start.Add(-time.Duration(svc.settings.Ramadan.SuhoorLeadTime) * time.Minute)
+				if suhoorTime.After(currentTime) {
+					alerts = append(alerts, Alert{ID: alertID(p, SUHOOR), Type: SUHOOR, Time: suhoorTime, Play: true})
+				}
+			}
+		case aladhan.Maghrib:
+			alerts = append(alerts, Alert{ID: alertID(p, IFTAR), Type: IFTAR, Time: p.Time, Play: true})
+		}
+	}
+	return alerts
+}
+
 // DisplayPrayerTimings renders upcoming calendar in ASCII table
 // https://github.com/olekukonko/tablewriter#example-6----identical-cells-merging
 func (svc *Service) DisplayPrayerTimings(writer io.Writer, dailyPrayerTimings []DailyPrayerTimings) {
 	table := tablewriter.NewWriter(writer)
-	table.SetHeader([]string{"Date", "Adhan", "Time", "Play"})
+	table.SetHeader([]string{"Date", "Hijri", "Adhan", "Time", "Play"})
 	table.SetAutoMergeCells(true)
 	table.SetRowLine(true)
 	for _, dpt := range dailyPrayerTimings {
+		// Alerts are displayed as timings of the prayer; i.e. "Fajr reminder"
+		timings := make([]Prayer, 0, len(dpt.Prayers)+len(dpt.Extras))
+		timings = append(timings, dpt.Prayers...)
+		timings = append(timings, dpt.Extras...)
 		for _, p := range dpt.Prayers {
-			year, month, day := p.Time.Date()
-			dateStr := fmt.Sprintf("%s %d-%s-%d", p.Time.Weekday(), day, month, year)
+			for _, alert := range p.Alerts {
+				alertType := aladhan.Adhan(fmt.Sprintf("%s %s", p.Type, alert.Type))
+				timings = append(timings, Prayer{ID: alert.ID, Play: alert.Play, Type: alertType, Time: alert.Time})
+			}
+		}
+		if len(timings) == 0 {
+			continue
+		}
+		sortPrayers(timings)
+
+		// Timings past midnight (i.e. midnight) are displayed on the date of the day's first timing
+		year, month, day := timings[0].Time.Date()
+		dateStr := fmt.Sprintf("%s %d-%s-%d", timings[0].Time.Weekday(), day, month, year)
+		hijriStr := fmt.Sprintf("%d %s %d", dpt.Hijri.Day, dpt.Hijri.Month.En, dpt.Hijri.Year)
+		for _, p := range timings {
 			var playStr string
 			if p.Play {
 				playStr = "Yes"
 			} else {
 				playStr = "No"
 			}
-			table.Append([]string{dateStr, string(p.Type), p.Time.Format("03:04:05 PM"), playStr})
+			table.Append([]string{dateStr, hijriStr, string(p.Type), p.Time.Format("03:04:05 PM"), playStr})
 		}
 	}
 	table.Render()
 }
 
-// populatePrayerTimings populates channel with upcaoming prayer times for the month
-func (svc *Service) populatePrayerTimings(prayerCh chan Prayer, dailyPrayerTimings []DailyPrayerTimings) {
-	for _, dpt := range dailyPrayerTimings {
-		for _, p := range dpt.Prayers {
-			if p.Time.After(time.Now()) {
-				prayerCh <- p
-			}
-		}
-	}
+// sortPrayers sorts prayers by time
+func sortPrayers(prayers []Prayer) {
+	sort.Slice(prayers, func(i, j int) bool {
+		return prayers[i].Time.Before(prayers[j].Time)
+	})
 }
 
-// playPrayerCalls plays the prayer calls for the month
-func (svc *Service) playPrayerCalls(prayerCh chan Prayer) error {
-	errs, _ := errgroup.WithContext(context.TODO())
-	var wg sync.WaitGroup
-
-	wg.Add(1)
-
-	errs.Go(func() error {
-		defer wg.Done()
-		for p := range prayerCh {
-			timeTillNextAdhan := time.Until(p.Time)
-
-			log.Printf(
-				"Adhan will play at %s, waiting %s for %s adhan...",
-				p.Time,
-				timeTillNextAdhan,
-				p.Type,
-			)
-
-			time.Sleep(timeTillNextAdhan)
-
-			dbP, err := svc.prayerDatabase.GetPrayerByTime(p.Time)
-			if err != nil {
-				return err
-			}
-
-			// Only play adhan if its set to execute
-			if dbP.Play {
-				log.Printf("Playing %s adhan at %s...", p.Type, p.Time)
-				if err := svc.player.Play(p.Type); err != nil {
-					return err
-				}
-			} else {
-				log.Printf("Skipping %s adhan at %s since execution is set to false", p.Type, p.Time)
-			}
-		}
-		return nil
-	})
+// GetSettings returns the location and calculation settings of the adhan timings
+func (svc *Service) GetSettings() Settings {
+	svc.mutex.RLock()
+	defer svc.mutex.RUnlock()
+	return svc.settings
+}
 
-	close(prayerCh)
-	wg.Wait()
+// GetLocation returns the location of the adhan timings, including the coordinates and timezone
+// resolved by the calendar provider
+func (svc *Service) GetLocation() Location {
+	svc.mutex.RLock()
+	defer svc.mutex.RUnlock()
+	return svc.location
+}
 
-	return errs.Wait()
+func (svc *Service) setLocation(location Location) {
+	svc.mutex.Lock()
+	defer svc.mutex.Unlock()
+	svc.location = location
 }
 
 // GetPrayerTimings returns the prayer timings for the current day
@@ -213,46 +594,66 @@ func (svc *Service) GetPrayerTimings() []DailyPrayerTimings {
 	return svc.prayerDatabase.Timings()
 }
 
-// TurnOffAllAdhan sets adhan executions for all adhan timings of the month to be muted
+// TurnOffAllAdhan sets adhan (and alert) executions for all adhan timings of the month to be muted
 func (svc *Service) TurnOffAllAdhan() {
+	svc.setAllAdhan(false)
+}
+
+// TurnOnAllAdhan sets adhan (and alert) executions for all adhan timings of the month to be played
+func (svc *Service) TurnOnAllAdhan() {
+	svc.setAllAdhan(true)
+}
+
+func (svc *Service) setAllAdhan(play bool) {
 	svc.mutex.Lock()
 	defer svc.mutex.Unlock()
 
-	for dptIndex, dpt := range svc.prayerDatabase.Timings() {
-		for i := range dpt.Prayers {
-			prayerTiming := dpt.Prayers[i]
-			prayerTiming.Play = false
-			svc.prayerDatabase.SetPrayerTime(dptIndex, i, prayerTiming)
+	for _, dpt := range svc.prayerDatabase.Timings() {
+		for _, prayerTiming := range append(append([]Prayer(nil), dpt.Prayers...), dpt.Extras...) {
+			if !playable(prayerTiming.Type) {
+				continue
+			}
+			prayerTiming.Play = play
+			prayerTiming.Alerts = append([]Alert(nil), prayerTiming.Alerts...)
+			for i := range prayerTiming.Alerts {
+				prayerTiming.Alerts[i].Play = play
+			}
+			if err := svc.prayerDatabase.SetPrayer(prayerTiming); err != nil {
+				log.Printf("error setting %s adhan: %s", prayerTiming.Type, err)
+				continue
+			}
+			svc.schedulePrayer(prayerTiming)
 		}
 	}
 }
 
-// TurnOnAllAdhan sets adhan executions for all adhan timings of the month to be played
-func (svc *Service) TurnOnAllAdhan() {
+// ToggleAdhan toggles a single adhan timings execution by matching its ID;
+// the tahajjud alarm is also toggled by its ID, whereas other non-adhan timings are not played
+func (svc *Service) ToggleAdhan(id string) (*Prayer, error) {
 	svc.mutex.Lock()
 	defer svc.mutex.Unlock()
 
-	for dptIndex, dpt := range svc.prayerDatabase.Timings() {
-		for i := range dpt.Prayers {
-			prayerTiming := dpt.Prayers[i]
-			prayerTiming.Play = true
-			svc.prayerDatabase.SetPrayerTime(dptIndex, i, prayerTiming)
-		}
+	prayerTiming, err := svc.prayerDatabase.GetPrayer(id)
+	if err != nil {
+		return nil, err
+	}
+	if !playable(prayerTiming.Type) {
+		return nil, fmt.Errorf("unable to toggle %s timing; id=%s", prayerTiming.Type, id)
 	}
+	return svc.toggleAdhan(prayerTiming)
 }
 
-// ToggleAdhan toggles a single adhan timings execution by matching its unix timestamp
-func (svc *Service) ToggleAdhan(index int) (*Prayer, error) {
+// ToggleAdhanByIndex toggles a single adhan timings execution by matching its index
+//
+// Deprecated: indexes are not stable across restarts and month rollovers; use ToggleAdhan
+func (svc *Service) ToggleAdhanByIndex(index int) (*Prayer, error) {
 	svc.mutex.Lock()
 	defer svc.mutex.Unlock()
 
-	for dptIndex, dpt := range svc.prayerDatabase.Timings() {
-		for prayerIndex := range dpt.Prayers {
-			if dpt.Prayers[prayerIndex].Index == uint8(index) {
-				prayerTiming := dpt.Prayers[prayerIndex]
-				prayerTiming.Play = !prayerTiming.Play
-				svc.prayerDatabase.SetPrayerTime(dptIndex, prayerIndex, prayerTiming)
-				return &prayerTiming, nil
+	for _, dpt := range svc.prayerDatabase.Timings() {
+		for _, prayerTiming := range dpt.Prayers {
+			if prayerTiming.Index == uint8(index) {
+				return svc.toggleAdhan(prayerTiming)
 			}
 		}
 	}
@@ -260,28 +661,69 @@ func (svc *Service) ToggleAdhan(index int) (*Prayer, error) {
 	return nil, fmt.Errorf("unable to find prayer with index; index=%d", index)
 }
 
-// getDateFromTimestamp retrieves time from a unix timestamp
-func getDateFromTimestamp(timestamp string) (time.Time, error) {
-	i, err := strconv.ParseInt(timestamp, 10, 64)
+func (svc *Service) toggleAdhan(prayerTiming Prayer) (*Prayer, error) {
+	prayerTiming.Play = !prayerTiming.Play
+	if err := svc.prayerDatabase.SetPrayer(prayerTiming); err != nil {
+		return nil, err
+	}
+	svc.schedulePrayer(prayerTiming)
+	return &prayerTiming, nil
+}
+
+// ToggleAlert toggles a single alert (i.e. reminder) execution by matching its ID
+func (svc *Service) ToggleAlert(id string) (*Alert, error) {
+	svc.mutex.Lock()
+	defer svc.mutex.Unlock()
+
+	for _, dpt := range svc.prayerDatabase.Timings() {
+		for _, prayerTiming := range dpt.Prayers {
+			for i := range prayerTiming.Alerts {
+				if prayerTiming.Alerts[i].ID != id {
+					continue
+				}
+				// Alerts are copied so that the stored prayer is only updated by the database
+				prayerTiming.Alerts = append([]Alert(nil), prayerTiming.Alerts...)
+				prayerTiming.Alerts[i].Play = !prayerTiming.Alerts[i].Play
+				if err := svc.prayerDatabase.SetPrayer(prayerTiming); err != nil {
+					return nil, err
+				}
+				svc.schedulePrayer(prayerTiming)
+				alert := prayerTiming.Alerts[i]
+				return &alert, nil
+			}
+		}
+	}
+
+	return nil, fmt.Errorf("unable to find alert; id=%s", id)
+}
+
+// getDate converts the readable date of the Adhan API (i.e. "01 Jan 2021") to the start of the day in the tz location
+func getDate(dateStr string, location string) (time.Time, error) {
+	tl, err := time.LoadLocation(location)
+	if err != nil {
+		return time.Time{}, fmt.Errorf(`incorrect location input: "%s"`, location)
+	}
+
+	t, err := time.ParseInLocation("02 Jan 2006", dateStr, tl)
 	if err != nil {
-		return time.Now(), err
+		return time.Time{}, fmt.Errorf(`incorrect date input: "%s"`, dateStr)
 	}
-	return time.Unix(i, 0), nil
+	return t, nil
 }
 
 // getTime converts string input with tz location to time object
 // https://yourbasic.org/golang/format-parse-string-time-date-example/
-func getTime(timeStr string, location string) time.Time {
+func getTime(timeStr string, location string) (time.Time, error) {
 	dateFormat := "02 Jan 2006 15:04 (MST)"
 
 	tl, err := time.LoadLocation(location)
 	if err != nil {
-		log.Fatalf(`Incorrect location input: "%s"`, location)
+		return time.Time{}, fmt.Errorf(`incorrect location input: "%s"`, location)
 	}
 
 	t, err := time.ParseInLocation(dateFormat, timeStr, tl)
 	if err != nil {
-		log.Fatalf(`Incorrect date-time input: "%s"`, timeStr)
+		return time.Time{}, fmt.Errorf(`incorrect date-time input: "%s"`, timeStr)
 	}
-	return t
+	return t, nil
 }
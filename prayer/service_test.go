@@ -1,8 +1,18 @@
 package prayer
 
 import (
+	"context"
+	"errors"
+	"io/ioutil"
+	"log"
+	"net/http"
+	"net/http/httptest"
+	"strings"
 	"testing"
 	"time"
+
+	"github.com/zees-dev/prayeralarm/aladhan"
+	"github.com/zees-dev/prayeralarm/praytime"
 )
 
 func TestGetTime(t *testing.T) {
@@ -10,7 +20,10 @@ func TestGetTime(t *testing.T) {
 		timeStr := "01 Jan 2021 04:14 (NZDT)"
 
 		tz := "Pacific/Auckland"
-		got := getTime(timeStr, tz)
+		got, err := getTime(timeStr, tz)
+		if err != nil {
+			t.Fatalf("unexpected error: %s", err)
+		}
 
 		l, _ := time.LoadLocation(tz)
 		want := time.Date(2021, 1, 1, 4, 14, 0, 0, l)
@@ -24,7 +37,10 @@ func TestGetTime(t *testing.T) {
 		timeStr := "01 Jan 2021 04:14 (EST)"
 
 		tz := "America/New_York"
-		got := getTime(timeStr, tz)
+		got, err := getTime(timeStr, tz)
+		if err != nil {
+			t.Fatalf("unexpected error: %s", err)
+		}
 
 		l, _ := time.LoadLocation(tz)
 		want := time.Date(2021, 1, 1, 4, 14, 0, 0, l)
@@ -38,7 +54,10 @@ func TestGetTime(t *testing.T) {
 		timeStr := "01 Jan 2021 04:14 (AWST)"
 
 		lc := "Australia/Perth"
-		got := getTime(timeStr, lc)
+		got, err := getTime(timeStr, lc)
+		if err != nil {
+			t.Fatalf("unexpected error: %s", err)
+		}
 
 		l, _ := time.LoadLocation(lc)
 		want := time.Date(2021, 1, 1, 4, 14, 0, 0, l)
@@ -48,3 +67,682 @@ func TestGetTime(t *testing.T) {
 		}
 	})
 }
+
+func TestGetTimeInvalidInput(t *testing.T) {
+	t.Run("test unknown timezone location", func(t *testing.T) {
+		if _, err := getTime("01 Jan 2021 04:14 (NZDT)", "Pacific/Nowhere"); err == nil {
+			t.Error("want error, got nil")
+		}
+	})
+
+	t.Run("test malformed date time", func(t *testing.T) {
+		if _, err := getTime("01 Jan 2021 4am", "Pacific/Auckland"); err == nil {
+			t.Error("want error, got nil")
+		}
+	})
+}
+
+// stubProvider is a calendar provider returning fixed calendar days
+type stubProvider struct {
+	days []CalendarDay
+	err  error
+}
+
+func (sp stubProvider) MonthCalendar(ctx context.Context, location Location, year int, month time.Month) (Calendar, error) {
+	return Calendar{Location: location, Days: sp.days}, sp.err
+}
+
+func TestGeneratePrayers(t *testing.T) {
+	t.Run("test only upcoming adhans are generated in time order", func(t *testing.T) {
+		now := time.Now()
+		today := time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, now.Location())
+		tomorrow := today.AddDate(0, 0, 1)
+
+		provider := stubProvider{days: []CalendarDay{
+			{
+				Date: today,
+				Timings: map[aladhan.Adhan]time.Time{
+					aladhan.Fajr:     now.Add(-time.Hour),
+					aladhan.Isha:     now.Add(2 * time.Hour),
+					aladhan.Asr:      now.Add(time.Hour),
+					aladhan.Midnight: now.Add(3 * time.Hour),
+				},
+			},
+			{
+				Date: tomorrow,
+				Timings: map[aladhan.Adhan]time.Time{
+					aladhan.Fajr: now.Add(24 * time.Hour),
+				},
+			},
+		}}
+		svc := NewService(NewStdOutPlayer(), NewPrayerDatabase(), provider, Settings{})
+
+		calendar, err := svc.calendarProvider.MonthCalendar(context.Background(), Location{}, now.Year(), now.Month())
+		if err != nil {
+			t.Fatalf("unexpected error: %s", err)
+		}
+		got, err := svc.generatePrayers(calendar.Days)
+		if err != nil {
+			t.Fatalf("unexpected error: %s", err)
+		}
+
+		if len(got) != 2 {
+			t.Fatalf("want 2 days, got %d", len(got))
+		}
+
+		want := []aladhan.Adhan{aladhan.Asr, aladhan.Isha}
+		if len(got[0].Prayers) != len(want) {
+			t.Fatalf("want %d prayers, got %d", len(want), len(got[0].Prayers))
+		}
+		for i, adhan := range want {
+			if got[0].Prayers[i].Type != adhan {
+				t.Errorf("want %s, got %s", adhan, got[0].Prayers[i].Type)
+			}
+		}
+
+		if len(got[0].Extras) != 1 || got[0].Extras[0].Type != aladhan.Midnight || got[0].Extras[0].Play {
+			t.Errorf("want midnight extra which is not played, got %+v", got[0].Extras)
+		}
+
+		if !got[1].Date.Equal(tomorrow) {
+			t.Errorf("want %s, got %s", tomorrow, got[1].Date)
+		}
+	})
+
+	t.Run("test timings past midnight are identified by their calendar day", func(t *testing.T) {
+		today := time.Now().AddDate(0, 0, 1)
+		today = time.Date(today.Year(), today.Month(), today.Day(), 0, 0, 0, 0, today.Location())
+		tomorrow := today.AddDate(0, 0, 1)
+		days := []CalendarDay{
+			{Date: today, Timings: map[aladhan.Adhan]time.Time{aladhan.Isha: tomorrow.Add(30 * time.Minute)}},
+			{Date: tomorrow, Timings: map[aladhan.Adhan]time.Time{aladhan.Isha: tomorrow.Add(23*time.Hour + 50*time.Minute)}},
+		}
+		svc := NewService(NewStdOutPlayer(), NewPrayerDatabase(), stubProvider{}, Settings{})
+		got, err := svc.generatePrayers(days)
+		if err != nil {
+			t.Fatalf("unexpected error: %s", err)
+		}
+		if len(got) != 2 {
+			t.Fatalf("want 2 days, got %d", len(got))
+		}
+
+		for i, date := range []time.Time{today, tomorrow} {
+			if want := prayerID(aladhan.Isha, date); got[i].Prayers[0].ID != want {
+				t.Errorf("want %s, got %s", want, got[i].Prayers[0].ID)
+			}
+		}
+	})
+}
+
+func TestScheduleAdhans(t *testing.T) {
+	now := time.Now()
+	provider := stubProvider{days: []CalendarDay{
+		{
+			Date: now,
+			Timings: map[aladhan.Adhan]time.Time{
+				aladhan.Asr:     now.Add(time.Hour),
+				aladhan.Maghrib: now.Add(2 * time.Hour),
+				aladhan.Sunset:  now.Add(90 * time.Minute),
+			},
+		},
+	}}
+	svc := NewService(NewStdOutPlayer(), NewPrayerDatabase(), provider, Settings{})
+	if err := svc.loadCalendar(context.Background(), now.Year(), now.Month()); err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+
+	t.Run("test adhans are scheduled when the calendar is loaded", func(t *testing.T) {
+		next, ok := svc.NextEvent()
+		if !ok || !next.Time.Equal(now.Add(time.Hour)) {
+			t.Errorf("want asr adhan event at %s, got %+v", now.Add(time.Hour), next)
+		}
+	})
+
+	t.Run("test toggled adhans are unscheduled and rescheduled", func(t *testing.T) {
+		if _, err := svc.ToggleAdhan(prayerID(aladhan.Asr, now.Add(time.Hour))); err != nil {
+			t.Fatalf("unexpected error: %s", err)
+		}
+		if next, _ := svc.NextEvent(); !next.Time.Equal(now.Add(2 * time.Hour)) {
+			t.Errorf("want maghrib adhan event at %s, got %+v", now.Add(2*time.Hour), next)
+		}
+
+		if _, err := svc.ToggleAdhanByIndex(0); err != nil {
+			t.Fatalf("unexpected error: %s", err)
+		}
+		if next, _ := svc.NextEvent(); !next.Time.Equal(now.Add(time.Hour)) {
+			t.Errorf("want asr adhan event at %s, got %+v", now.Add(time.Hour), next)
+		}
+	})
+
+	t.Run("test toggling unknown adhan", func(t *testing.T) {
+		if _, err := svc.ToggleAdhan(prayerID(aladhan.Fajr, now)); err == nil {
+			t.Error("want error, got nil")
+		}
+	})
+
+	t.Run("test turning off all adhans unschedules them", func(t *testing.T) {
+		svc.TurnOffAllAdhan()
+		for _, event := range svc.scheduler.Events() {
+			if event.ID != prefetchEventID {
+				t.Errorf("want only %s event, got %s", prefetchEventID, event.ID)
+			}
+		}
+	})
+}
+
+func TestCalendarRollover(t *testing.T) {
+	// The last day of the month is tomorrow, with isha and tahajjud past midnight of the first day of the next month
+	tomorrow := time.Now().AddDate(0, 0, 1)
+	last := time.Date(tomorrow.Year(), tomorrow.Month(), tomorrow.Day(), 0, 0, 0, 0, time.Local)
+	first := last.AddDate(0, 0, 1)
+	lastDay := CalendarDay{Date: last, Timings: map[aladhan.Adhan]time.Time{
+		aladhan.Fajr:    last.Add(5 * time.Hour),
+		aladhan.Maghrib: last.Add(18 * time.Hour),
+		aladhan.Isha:    first.Add(30 * time.Minute),
+	}}
+	firstDay := CalendarDay{Date: first, Timings: map[aladhan.Adhan]time.Time{
+		aladhan.Fajr:    first.Add(5 * time.Hour),
+		aladhan.Maghrib: first.Add(18 * time.Hour),
+	}}
+
+	svc := NewService(NewStdOutPlayer(), NewPrayerDatabase(), stubProvider{days: []CalendarDay{lastDay}}, Settings{Tahajjud: LASTTHIRD})
+	if err := svc.loadCalendar(context.Background(), last.Year(), last.Month()); err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	// The next month is loaded once the adhans of the last day have been played, prior to its trailing night
+	now := time.Now()
+	for _, dpt := range svc.prayerDatabase.Timings() {
+		for i := range dpt.Prayers {
+			if dpt.Prayers[i].Time.Before(first) {
+				dpt.Prayers[i].Time = now.Add(-time.Hour)
+				svc.scheduler.Remove(adhanEventID(dpt.Prayers[i]))
+			}
+		}
+	}
+	svc.calendarProvider = stubProvider{days: []CalendarDay{firstDay}}
+	if err := svc.loadCalendar(context.Background(), first.Year(), first.Month()); err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+
+	ishaID, tahajjudID := prayerID(aladhan.Isha, last), prayerID(aladhan.Tahajjud, last.Add(18*time.Hour))
+	t.Run("test trailing night of the previous month is carried over", func(t *testing.T) {
+		timings := svc.GetPrayerTimings()
+		if len(timings) != 2 || !timings[0].Date.Equal(last) || !timings[1].Date.Equal(first) {
+			t.Fatalf("want timings of %s and %s, got %+v", last, first, timings)
+		}
+		if got := timings[0].Prayers; len(got) != 1 || got[0].ID != ishaID {
+			t.Errorf("want [%s], got %+v", ishaID, got)
+		}
+		if got := timings[0].Extras; len(got) != 1 || got[0].ID != tahajjudID {
+			t.Errorf("want [%s], got %+v", tahajjudID, got)
+		}
+		for i, p := range append(timings[0].Prayers, timings[1].Prayers...) {
+			if int(p.Index) != i {
+				t.Errorf("want index %d of %s, got %d", i, p.ID, p.Index)
+			}
+		}
+	})
+
+	t.Run("test scheduled timings of the trailing night can be toggled", func(t *testing.T) {
+		for _, id := range []string{ishaID, tahajjudID} {
+			if _, err := svc.ToggleAdhan(id); err != nil {
+				t.Fatalf("unexpected error: %s", err)
+			}
+		}
+		for _, event := range svc.scheduler.Events() {
+			if event.Time.Before(firstDay.Timings[aladhan.Fajr]) {
+				t.Errorf("want trailing night events to be unscheduled, got %s", event.ID)
+			}
+		}
+	})
+}
+
+func TestReminders(t *testing.T) {
+	now := time.Now()
+	asr := now.Add(time.Hour)
+	provider := stubProvider{days: []CalendarDay{
+		{
+			Date: now,
+			Timings: map[aladhan.Adhan]time.Time{
+				aladhan.Asr:  asr,
+				aladhan.Isha: now.Add(5 * time.Minute),
+			},
+		},
+	}}
+	settings := Settings{Reminders: Reminders{aladhan.Asr: 15, aladhan.Isha: 10}}
+	svc := NewService(NewStdOutPlayer(), NewPrayerDatabase(), provider, settings)
+	if err := svc.loadCalendar(context.Background(), now.Year(), now.Month()); err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	reminder := alertID(Prayer{ID: prayerID(aladhan.Asr, asr)}, REMINDER)
+
+	t.Run("test upcoming reminders are scheduled prior to the adhan", func(t *testing.T) {
+		timings := svc.GetPrayerTimings()[0].Prayers
+		if len(timings[0].Alerts) != 0 {
+			t.Errorf("want no reminder for isha within lead time, got %+v", timings[0].Alerts)
+		}
+		if len(timings[1].Alerts) != 1 || timings[1].Alerts[0].ID != reminder {
+			t.Fatalf("want %s, got %+v", reminder, timings[1].Alerts)
+		}
+
+		want := asr.Add(-15 * time.Minute)
+		var scheduled bool
+		for _, event := range svc.scheduler.Events() {
+			scheduled = scheduled || (event.ID == "alert-"+reminder && event.Time.Equal(want))
+		}
+		if !scheduled {
+			t.Errorf("want reminder event at %s, got %+v", want, svc.scheduler.Events())
+		}
+	})
+
+	t.Run("test toggled reminders are unscheduled independently of the adhan", func(t *testing.T) {
+		alert, err := svc.ToggleAlert(reminder)
+		if err != nil {
+			t.Fatalf("unexpected error: %s", err)
+		}
+		if alert.Play {
+			t.Error("want muted reminder, got played")
+		}
+		if got := svc.GetPrayerTimings()[0].Prayers[1]; !got.Play || got.Alerts[0].Play {
+			t.Errorf("want played adhan with muted reminder, got %+v", got)
+		}
+		for _, event := range svc.scheduler.Events() {
+			if event.ID == "alert-"+reminder {
+				t.Errorf("want reminder to be unscheduled, got %+v", event)
+			}
+		}
+	})
+}
+
+func TestParseReminders(t *testing.T) {
+	t.Run("test lead times are parsed for the 5 daily adhans", func(t *testing.T) {
+		got, err := ParseReminders("10,0,0,5,0")
+		if err != nil {
+			t.Fatalf("unexpected error: %s", err)
+		}
+		if len(got) != 2 || got[aladhan.Fajr] != 10 || got[aladhan.Maghrib] != 5 {
+			t.Errorf("want fajr and maghrib reminders, got %+v", got)
+		}
+	})
+
+	t.Run("test invalid lead times", func(t *testing.T) {
+		for _, reminders := range []string{"10,0,0,0", "10,0,0,0,-5", "a,0,0,0,0"} {
+			if _, err := ParseReminders(reminders); err == nil {
+				t.Errorf("%s: want error, got nil", reminders)
+			}
+		}
+	})
+
+	t.Run("test invalid lead time is reported by adhan", func(t *testing.T) {
+		_, err := ParseReminders("10,0,x,0,0")
+		if want := `invalid reminders "10,0,x,0,0"; err=invalid integer "x" for Asr`; err == nil || err.Error() != want {
+			t.Errorf("want %s, got %v", want, err)
+		}
+	})
+}
+
+// blockingPlayer is a player which plays until released or closed
+type blockingPlayer struct {
+	started chan Cue
+	release chan struct{}
+	closed  chan struct{}
+}
+
+func newBlockingPlayer() *blockingPlayer {
+	return &blockingPlayer{
+		started: make(chan Cue, 1),
+		release: make(chan struct{}),
+		closed:  make(chan struct{}),
+	}
+}
+
+func (bp *blockingPlayer) Play(ctx context.Context, cue Cue) error {
+	bp.started <- cue
+	select {
+	case <-bp.release:
+		return nil
+	case <-bp.closed:
+		return ErrPlaybackStopped
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+func (bp *blockingPlayer) Close() error {
+	close(bp.closed)
+	return nil
+}
+
+func TestShutdown(t *testing.T) {
+	// startPlayback runs the service until the adhan starts playing
+	startPlayback := func(t *testing.T, player *blockingPlayer) (*Service, context.CancelFunc) {
+		now := time.Now()
+		provider := stubProvider{days: []CalendarDay{
+			{Date: now, Timings: map[aladhan.Adhan]time.Time{aladhan.Asr: now.Add(10 * time.Millisecond)}},
+		}}
+		svc := NewService(player, NewPrayerDatabase(), provider, Settings{})
+		ctx, cancel := context.WithCancel(context.Background())
+		go svc.InitialisePrayeralarm(ctx, now.Year(), now.Month())
+
+		select {
+		case <-player.started:
+		case <-time.After(time.Second):
+			t.Fatal("want adhan to be played, got timeout")
+		}
+		return svc, cancel
+	}
+
+	t.Run("test in-flight playback is stopped after grace period", func(t *testing.T) {
+		player := newBlockingPlayer()
+		svc, cancel := startPlayback(t, player)
+		cancel()
+
+		ctx, cancelGrace := context.WithTimeout(context.Background(), 10*time.Millisecond)
+		defer cancelGrace()
+		if err := svc.Shutdown(ctx); err != nil {
+			t.Fatalf("unexpected error: %s", err)
+		}
+		select {
+		case <-player.closed:
+		default:
+			t.Error("want player to be closed, got open")
+		}
+	})
+
+	t.Run("test in-flight playback finishes within grace period", func(t *testing.T) {
+		player := newBlockingPlayer()
+		svc, cancel := startPlayback(t, player)
+		cancel()
+
+		ctx, cancelGrace := context.WithTimeout(context.Background(), time.Second)
+		defer cancelGrace()
+		go close(player.release)
+		if err := svc.Shutdown(ctx); err != nil {
+			t.Fatalf("unexpected error: %s", err)
+		}
+		if ctx.Err() != nil {
+			t.Errorf("want shutdown within grace period, got %s", ctx.Err())
+		}
+	})
+}
+
+func TestStopPlayback(t *testing.T) {
+	// playCue plays the cue on the service in the background, returning the playback error once finished
+	playCue := func(svc *Service, cue Cue) <-chan error {
+		done := make(chan error, 1)
+		go func() { done <- svc.play(cue) }()
+		return done
+	}
+	waitStopped := func(t *testing.T, done <-chan error, want error) {
+		select {
+		case err := <-done:
+			if !errors.Is(err, want) {
+				t.Errorf("want %s, got %v", want, err)
+			}
+		case <-time.After(time.Second):
+			t.Fatal("want playback to be stopped, got timeout")
+		}
+	}
+
+	t.Run("test in-flight playback is stopped", func(t *testing.T) {
+		player := newBlockingPlayer()
+		svc := NewService(player, NewPrayerDatabase(), stubProvider{}, Settings{})
+		if _, err := svc.StopPlayback(); !errors.Is(err, ErrNoPlayback) {
+			t.Errorf("want %s, got %v", ErrNoPlayback, err)
+		}
+
+		done := playCue(svc, Cue{Adhan: aladhan.Fajr})
+		<-player.started
+		cue, err := svc.StopPlayback()
+		if err != nil {
+			t.Fatalf("unexpected error: %s", err)
+		}
+		if cue.Adhan != aladhan.Fajr {
+			t.Errorf("want %s, got %s", aladhan.Fajr, cue.Adhan)
+		}
+		waitStopped(t, done, context.Canceled)
+
+		if _, err := svc.StopPlayback(); !errors.Is(err, ErrNoPlayback) {
+			t.Errorf("want %s, got %v", ErrNoPlayback, err)
+		}
+	})
+
+	t.Run("test playback is stopped after timeout", func(t *testing.T) {
+		player := newBlockingPlayer()
+		settings := Settings{Playback: PlaybackPolicy{Timeout: 10 * time.Millisecond}}
+		svc := NewService(player, NewPrayerDatabase(), stubProvider{}, settings)
+
+		done := playCue(svc, Cue{Adhan: aladhan.Fajr})
+		<-player.started
+		waitStopped(t, done, context.DeadlineExceeded)
+	})
+
+	t.Run("test scheduled playback stopped after timeout is not logged as an error", func(t *testing.T) {
+		logs := &tailBuffer{size: maxStderrSize}
+		defer log.SetOutput(log.Writer())
+		log.SetOutput(logs)
+
+		player := newBlockingPlayer()
+		settings := Settings{Playback: PlaybackPolicy{Timeout: 10 * time.Millisecond}}
+		svc := NewService(player, NewPrayerDatabase(), stubProvider{}, settings)
+		svc.scheduleCue("fajr", true, time.Now().Add(time.Hour), Cue{Adhan: aladhan.Fajr})
+		for _, event := range svc.scheduler.Events() {
+			event.Action(context.Background())
+		}
+
+		if got := logs.String(); !strings.Contains(got, "stopped playing Fajr adhan; exceeded playback timeout of 10ms") || strings.Contains(got, "error playing") {
+			t.Errorf("want playback timeout to be logged as stopped, got %s", got)
+		}
+	})
+}
+
+func TestStoppableReader(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	var stopped int32
+	reader := stoppableReader{ctx: ctx, reader: strings.NewReader("adhan"), stopped: &stopped}
+
+	buf := make([]byte, 2)
+	if _, err := reader.Read(buf); err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	cancel()
+	if _, err := reader.Read(buf); !errors.Is(err, context.Canceled) {
+		t.Errorf("want %s, got %v", context.Canceled, err)
+	}
+}
+
+func TestCalculationProvider(t *testing.T) {
+	t.Run("test calculates offset adhan timings for every day of the month", func(t *testing.T) {
+		location := Location{Latitude: -36.8484597, Longitude: 174.7633315, Timezone: "Pacific/Auckland"}
+		settings := Settings{Location: location, Calculation: praytime.DefaultParams(praytime.MWL), Offsets: "1,0,0,0,0"}
+		provider, err := GetCalendarProvider(CALCULATION, settings, aladhan.NewClient(), "")
+		if err != nil {
+			t.Fatalf("unexpected error: %s", err)
+		}
+
+		calendar, err := provider.MonthCalendar(context.Background(), location, 2021, time.January)
+		if err != nil {
+			t.Fatalf("unexpected error: %s", err)
+		}
+
+		if len(calendar.Days) != 31 {
+			t.Fatalf("want 31 days, got %d", len(calendar.Days))
+		}
+
+		l, _ := time.LoadLocation(location.Timezone)
+		want := time.Date(2021, 1, 1, 4, 15, 0, 0, l)
+		if got := calendar.Days[0].Timings[aladhan.Fajr]; !got.Equal(want) {
+			t.Errorf("want %s, got %s", want, got)
+		}
+		if got := calendar.Days[0].Hijri; got.Day != 17 || got.Month.Number != 5 || got.Year != 1442 {
+			t.Errorf("want %s, got %+v", "17 Jumada al-Awwal 1442", got)
+		}
+	})
+
+	t.Run("test coordinates are required", func(t *testing.T) {
+		settings := Settings{Location: Location{City: "Auckland"}, Calculation: praytime.DefaultParams(praytime.MWL), Offsets: "0,0,0,0,0"}
+		if _, err := GetCalendarProvider(CALCULATION, settings, aladhan.NewClient(), ""); err == nil {
+			t.Error("want error, got nil")
+		}
+	})
+
+	t.Run("test no high latitude adjustment is rejected by the aladhan provider", func(t *testing.T) {
+		params := praytime.DefaultParams(praytime.MWL)
+		params.HighLatitude = praytime.NoAdjustment
+		settings := Settings{Location: Location{City: "Auckland"}, Calculation: params, Offsets: "0,0,0,0,0"}
+		if _, err := GetCalendarProvider(ALADHAN, settings, aladhan.NewClient(), ""); err == nil {
+			t.Error("want error, got nil")
+		}
+	})
+}
+
+func TestAladhanParams(t *testing.T) {
+	t.Run("test hanafi school and high latitude rule are passed through", func(t *testing.T) {
+		params := praytime.DefaultParams(praytime.ISNA)
+		params.School = praytime.Hanafi
+		params.HighLatitude = praytime.OneSeventh
+
+		got := aladhanParams(params)
+		want := aladhan.Params{Method: 2, School: 1, LatitudeAdjustmentMethod: 2}
+		if got != want {
+			t.Errorf("want %+v, got %+v", want, got)
+		}
+	})
+
+	t.Run("test custom method angles are passed as method settings", func(t *testing.T) {
+		got := aladhanParams(praytime.DefaultParams(praytime.Custom(18.5, 17.5)))
+		want := aladhan.Params{Method: 99, MethodSettings: "18.5,null,17.5", LatitudeAdjustmentMethod: 3}
+		if got != want {
+			t.Errorf("want %+v, got %+v", want, got)
+		}
+	})
+}
+
+func TestAladhanProvider(t *testing.T) {
+	fixture, err := ioutil.ReadFile("../aladhan/testdata/calendar-auckland-2021-01.json")
+	if err != nil {
+		t.Fatalf("unable to read fixture: %s", err)
+	}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write(fixture)
+	}))
+	defer server.Close()
+
+	client := aladhan.NewClient()
+	client.BaseURL = server.URL
+	client.Retry.MaxRetries = 0
+
+	location := Location{City: "Auckland", Country: "NewZealand"}
+	settings := Settings{Location: location, Calculation: praytime.DefaultParams(praytime.MWL), Offsets: "0,0,0,0,0"}
+	l, _ := time.LoadLocation("Pacific/Auckland")
+
+	t.Run("test timings are parsed in the resolved timezone", func(t *testing.T) {
+		provider := NewAladhanProvider(client, settings, "")
+
+		calendar, err := provider.MonthCalendar(context.Background(), location, 2021, time.January)
+		if err != nil {
+			t.Fatalf("unexpected error: %s", err)
+		}
+
+		want := time.Date(2021, 1, 1, 4, 14, 0, 0, l)
+		if got := calendar.Days[0].Timings[aladhan.Fajr]; !got.Equal(want) {
+			t.Errorf("want %s, got %s", want, got)
+		}
+		wantMidnight := time.Date(2021, 1, 2, 1, 24, 0, 0, l)
+		if got := calendar.Days[0].Timings[aladhan.Midnight]; !got.Equal(wantMidnight) {
+			t.Errorf("want %s, got %s", wantMidnight, got)
+		}
+		if calendar.Location.Timezone != "Pacific/Auckland" || calendar.Location.Latitude != -36.8484597 {
+			t.Errorf("want resolved location, got %+v", calendar.Location)
+		}
+		wantHijri := praytime.HijriDate{Day: 17, Month: praytime.HijriMonth{Number: 5, En: "Jumādá al-ūlá", Ar: "جُمادى الأولى"}, Year: 1442}
+		if got := calendar.Days[0].Hijri; got != wantHijri {
+			t.Errorf("want %+v, got %+v", wantHijri, got)
+		}
+	})
+
+	t.Run("test hijri adjustment is passed to the API", func(t *testing.T) {
+		adjustments := make(chan string, 1)
+		adjustedServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			adjustments <- r.URL.Query().Get("adjustment")
+			w.Write(fixture)
+		}))
+		defer adjustedServer.Close()
+
+		adjustedClient := aladhan.NewClient()
+		adjustedClient.BaseURL = adjustedServer.URL
+		adjustedClient.Retry.MaxRetries = 0
+		adjusted := settings
+		adjusted.HijriAdjustment = -1
+
+		if _, err := NewAladhanProvider(adjustedClient, adjusted, "").MonthCalendar(context.Background(), location, 2021, time.January); err != nil {
+			t.Fatalf("unexpected error: %s", err)
+		}
+		if got := <-adjustments; got != "-1" {
+			t.Errorf("want %s, got %s", "-1", got)
+		}
+	})
+
+	t.Run("test cached calendar is used when API is unreachable", func(t *testing.T) {
+		cachedClient := aladhan.NewClient()
+		cachedClient.BaseURL = server.URL
+		cachedClient.Retry.MaxRetries = 0
+		provider := NewAladhanProvider(cachedClient, settings, t.TempDir())
+
+		if _, err := provider.MonthCalendar(context.Background(), location, 2021, time.January); err != nil {
+			t.Fatalf("unexpected error: %s", err)
+		}
+
+		cachedClient.BaseURL = "http://127.0.0.1:0"
+		calendar, err := provider.MonthCalendar(context.Background(), location, 2021, time.January)
+		if err != nil {
+			t.Fatalf("unexpected error: %s", err)
+		}
+		if len(calendar.Days) != 3 {
+			t.Errorf("want %d cached days, got %d", 3, len(calendar.Days))
+		}
+
+		if _, err := provider.MonthCalendar(context.Background(), location, 2021, time.February); err == nil {
+			t.Error("want error for uncached month, got nil")
+		}
+	})
+}
+
+func TestAladhanProviderLocalTime(t *testing.T) {
+	fixture, err := ioutil.ReadFile("../aladhan/testdata/calendar-auckland-2021-01.json")
+	if err != nil {
+		t.Fatalf("unable to read fixture: %s", err)
+	}
+	// The local time zone is set prior to starting the server, and restored once the server is closed
+	local := time.Local
+	defer func() { time.Local = local }()
+	time.Local, _ = time.LoadLocation("America/Los_Angeles")
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write(fixture)
+	}))
+	defer server.Close()
+
+	client := aladhan.NewClient()
+	client.BaseURL = server.URL
+	client.Retry.MaxRetries = 0
+
+	location := Location{City: "Auckland", Country: "NewZealand"}
+	settings := Settings{Location: location, Calculation: praytime.DefaultParams(praytime.MWL), Offsets: "0,0,0,0,0"}
+	l, _ := time.LoadLocation("Pacific/Auckland")
+
+	t.Run("test dates are the gregorian days of the resolved timezone regardless of local time", func(t *testing.T) {
+		calendar, err := NewAladhanProvider(client, settings, "").MonthCalendar(context.Background(), location, 2021, time.January)
+		if err != nil {
+			t.Fatalf("unexpected error: %s", err)
+		}
+
+		want := time.Date(2021, 1, 1, 0, 0, 0, 0, l)
+		if got := calendar.Days[0].Date; !got.Equal(want) || got.Location().String() != l.String() {
+			t.Errorf("want %s, got %s", want, got)
+		}
+		if got := prayerID(aladhan.Fajr, calendar.Days[0].Date); got != "2021-01-01-fajr" {
+			t.Errorf("want %s, got %s", "2021-01-01-fajr", got)
+		}
+	})
+}
@@ -0,0 +1,78 @@
+package prayer
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/zees-dev/prayeralarm/aladhan"
+)
+
+// TahajjudMode is the portion of the night from which the tahajjud alarm is played; empty for no alarm
+type TahajjudMode string
+
+const (
+	LASTTHIRD TahajjudMode = "last-third"
+	MIDNIGHT  TahajjudMode = "midnight"
+)
+
+// ParseTahajjudMode parses the tahajjud mode; `last-third`, `midnight`, or empty for no alarm
+func ParseTahajjudMode(mode string) (TahajjudMode, error) {
+	switch TahajjudMode(mode) {
+	case "", LASTTHIRD, MIDNIGHT:
+		return TahajjudMode(mode), nil
+	default:
+		return "", fmt.Errorf("undefined tahajjud mode '%s'", mode)
+	}
+}
+
+// tahajjudPrayer returns the tahajjud of the night following the calendar day; the night spans from maghrib
+// to fajr of the next day, which is estimated from fajr of the day when the next day is unknown (i.e. at the end
+// of the month)
+func (m TahajjudMode) tahajjudPrayer(day CalendarDay, nextDay *CalendarDay) (Prayer, bool) {
+	if m == "" {
+		return Prayer{}, false
+	}
+	maghrib, ok := day.Timings[aladhan.Maghrib]
+	if !ok {
+		return Prayer{}, false
+	}
+	var fajr time.Time
+	if nextFajr, ok := nextDay.fajr(); ok {
+		fajr = nextFajr
+	} else if dayFajr, ok := day.Timings[aladhan.Fajr]; ok {
+		fajr = dayFajr.AddDate(0, 0, 1)
+	} else {
+		return Prayer{}, false
+	}
+	night := fajr.Sub(maghrib)
+	if night <= 0 {
+		return Prayer{}, false
+	}
+
+	var tahajjudTime time.Time
+	switch m {
+	case LASTTHIRD:
+		tahajjudTime = maghrib.Add(night * 2 / 3)
+	case MIDNIGHT:
+		tahajjudTime = maghrib.Add(night / 2)
+	default:
+		return Prayer{}, false
+	}
+	tahajjudTime = tahajjudTime.Truncate(time.Minute)
+	// Identified by the date of the night's maghrib, whether the alarm is prior to or past midnight
+	return Prayer{ID: prayerID(aladhan.Tahajjud, maghrib), Play: true, Type: aladhan.Tahajjud, Time: tahajjudTime}, true
+}
+
+// fajr returns the fajr timing of the calendar day, if the day is known
+func (day *CalendarDay) fajr() (time.Time, bool) {
+	if day == nil {
+		return time.Time{}, false
+	}
+	fajr, ok := day.Timings[aladhan.Fajr]
+	return fajr, ok
+}
+
+// playable reports whether the timing has audio played at its time; adhans and the tahajjud alarm
+func playable(adhan aladhan.Adhan) bool {
+	return adhan.IsPrayer() || adhan == aladhan.Tahajjud
+}
@@ -0,0 +1,110 @@
+package prayer
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/zees-dev/prayeralarm/aladhan"
+)
+
+func TestTahajjudPrayer(t *testing.T) {
+	date := time.Date(2021, 1, 31, 0, 0, 0, 0, time.UTC)
+	day := CalendarDay{Date: date, Timings: map[aladhan.Adhan]time.Time{
+		aladhan.Fajr:    date.Add(4 * time.Hour),
+		aladhan.Maghrib: date.Add(19 * time.Hour),
+	}}
+	nextDay := CalendarDay{Date: date.AddDate(0, 0, 1), Timings: map[aladhan.Adhan]time.Time{
+		aladhan.Fajr: date.Add(28*time.Hour + 30*time.Minute),
+	}}
+
+	tests := []struct {
+		name    string
+		mode    TahajjudMode
+		nextDay *CalendarDay
+		want    time.Time
+	}{
+		// 9.5 hour night from 7 PM to 4:30 AM
+		{"test last third of the night", LASTTHIRD, &nextDay, date.Add(25*time.Hour + 20*time.Minute)},
+		{"test islamic midnight", MIDNIGHT, &nextDay, date.Add(23*time.Hour + 45*time.Minute)},
+		// 9 hour night from 7 PM to 4 AM, estimated from fajr of the day
+		{"test night estimated at the end of the month", LASTTHIRD, nil, date.Add(25 * time.Hour)},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, ok := tt.mode.tahajjudPrayer(day, tt.nextDay)
+			if !ok {
+				t.Fatal("want tahajjud, got none")
+			}
+			if !got.Time.Equal(tt.want) {
+				t.Errorf("want %s, got %s", tt.want, got.Time)
+			}
+			if want := "2021-01-31-tahajjud"; got.ID != want {
+				t.Errorf("want %s, got %s", want, got.ID)
+			}
+		})
+	}
+
+	t.Run("test no tahajjud when disabled", func(t *testing.T) {
+		if got, ok := TahajjudMode("").tahajjudPrayer(day, &nextDay); ok {
+			t.Errorf("want no tahajjud, got %+v", got)
+		}
+	})
+}
+
+func TestToggleTahajjud(t *testing.T) {
+	tomorrow := time.Now().AddDate(0, 0, 1)
+	date := time.Date(tomorrow.Year(), tomorrow.Month(), tomorrow.Day(), 0, 0, 0, 0, time.Local)
+	provider := stubProvider{days: []CalendarDay{{Date: date, Timings: map[aladhan.Adhan]time.Time{
+		aladhan.Fajr:     date.Add(5 * time.Hour),
+		aladhan.Maghrib:  date.Add(18 * time.Hour),
+		aladhan.Midnight: date.Add(23 * time.Hour),
+	}}}}
+	svc := NewService(NewStdOutPlayer(), NewPrayerDatabase(), provider, Settings{Tahajjud: LASTTHIRD})
+	if err := svc.loadCalendar(context.Background(), date.Year(), date.Month()); err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+
+	var tahajjud, midnight Prayer
+	for _, p := range svc.GetPrayerTimings()[0].Extras {
+		switch p.Type {
+		case aladhan.Tahajjud:
+			tahajjud = p
+		case aladhan.Midnight:
+			midnight = p
+		}
+	}
+	if !tahajjud.Play {
+		t.Fatalf("want tahajjud to be played, got %+v", tahajjud)
+	}
+	scheduled := func() bool {
+		for _, event := range svc.scheduler.Events() {
+			if event.ID == adhanEventID(tahajjud) {
+				return true
+			}
+		}
+		return false
+	}
+	if !scheduled() {
+		t.Errorf("want %s to be scheduled", adhanEventID(tahajjud))
+	}
+
+	t.Run("test tahajjud is toggled by id", func(t *testing.T) {
+		got, err := svc.ToggleAdhan(tahajjud.ID)
+		if err != nil {
+			t.Fatalf("unexpected error: %s", err)
+		}
+		if got.Play {
+			t.Errorf("want tahajjud to be muted, got %+v", got)
+		}
+		if scheduled() {
+			t.Errorf("want %s to be unscheduled", adhanEventID(tahajjud))
+		}
+	})
+
+	t.Run("test other non-adhan timings are not toggled", func(t *testing.T) {
+		if _, err := svc.ToggleAdhan(midnight.ID); err == nil {
+			t.Error("want error, got nil")
+		}
+	})
+}
@@ -0,0 +1,58 @@
+package praytime
+
+import (
+	"math"
+	"time"
+)
+
+// HijriMonth holds the names of a month of the hijri calendar
+type HijriMonth struct {
+	Number int    `json:"number"`
+	En     string `json:"en"`
+	Ar     string `json:"ar"`
+}
+
+// HijriMonths are the months of the hijri calendar
+var HijriMonths = [12]HijriMonth{
+	{Number: 1, En: "Muharram", Ar: "مُحَرَّم"},
+	{Number: 2, En: "Safar", Ar: "صَفَر"},
+	{Number: 3, En: "Rabi al-Awwal", Ar: "رَبيع الأوّل"},
+	{Number: 4, En: "Rabi al-Thani", Ar: "رَبيع الثاني"},
+	{Number: 5, En: "Jumada al-Awwal", Ar: "جُمادى الأولى"},
+	{Number: 6, En: "Jumada al-Thani", Ar: "جُمادى الآخرة"},
+	{Number: 7, En: "Rajab", Ar: "رَجَب"},
+	{Number: 8, En: "Shaban", Ar: "شَعْبان"},
+	{Number: 9, En: "Ramadan", Ar: "رَمَضان"},
+	{Number: 10, En: "Shawwal", Ar: "شَوّال"},
+	{Number: 11, En: "Dhu al-Qadah", Ar: "ذوالقعدة"},
+	{Number: 12, En: "Dhu al-Hijjah", Ar: "ذوالحجة"},
+}
+
+// Ramadan is the hijri month number of Ramadan
+const Ramadan = 9
+
+// HijriDate is a date of the hijri calendar
+type HijriDate struct {
+	Day   int        `json:"day"`
+	Month HijriMonth `json:"month"`
+	Year  int        `json:"year"`
+}
+
+// ToHijri converts the date (year, month, day) of `date` to the tabular (arithmetic) hijri calendar;
+// adjustment shifts the hijri date by a number of days to match local moon sighting
+// https://en.wikipedia.org/wiki/Tabular_Islamic_calendar
+func ToHijri(date time.Time, adjustment int) HijriDate {
+	year, month, day := date.Date()
+	jdn := int(math.Floor(julian(year, int(month), day)+0.5)) + adjustment
+
+	l := jdn - 1948440 + 10632
+	n := (l - 1) / 10631
+	l = l - 10631*n + 354
+	j := ((10985-l)/5316)*((50*l)/17719) + (l/5670)*((43*l)/15238)
+	l = l - ((30-j)/15)*((17719*j)/50) - (j/16)*((15238*j)/43) + 29
+	m := (24 * l) / 709
+	d := l - (709*m)/24
+	y := 30*n + j - 30
+
+	return HijriDate{Day: d, Month: HijriMonths[m-1], Year: y}
+}
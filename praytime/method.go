@@ -0,0 +1,204 @@
+package praytime
+
+import (
+	"fmt"
+	"strings"
+)
+
+// AsrSchool is the juristic school used to calculate Asr time
+type AsrSchool int
+
+const (
+	// Standard (Shafi, Maliki, Hanbali) - shadow length equal to object length
+	Standard AsrSchool = iota
+	// Hanafi - shadow length twice the object length
+	Hanafi
+)
+
+var asrSchoolNames = map[AsrSchool]string{Standard: "standard", Hanafi: "hanafi"}
+
+func (s AsrSchool) String() string {
+	return asrSchoolNames[s]
+}
+
+func (s AsrSchool) MarshalText() ([]byte, error) {
+	return []byte(s.String()), nil
+}
+
+// ParseAsrSchool returns the Asr school by name; `standard` or `hanafi`
+func ParseAsrSchool(name string) (AsrSchool, error) {
+	for school, schoolName := range asrSchoolNames {
+		if strings.EqualFold(name, schoolName) {
+			return school, nil
+		}
+	}
+	return Standard, fmt.Errorf("undefined asr school '%s'", name)
+}
+
+// HighLatitudeRule is the method used to adjust Fajr and Isha at higher latitudes
+// https://aladhan.com/calculation-methods
+type HighLatitudeRule int
+
+const (
+	// NoAdjustment applies no high latitude adjustment
+	NoAdjustment HighLatitudeRule = iota
+	// MiddleOfNight limits Fajr and Isha to half of the night
+	MiddleOfNight
+	// OneSeventh limits Fajr and Isha to a seventh of the night
+	OneSeventh
+	// AngleBased limits Fajr and Isha to a portion of the night derived from the twilight angle
+	AngleBased
+)
+
+var highLatitudeRuleNames = map[HighLatitudeRule]string{
+	NoAdjustment:  "none",
+	MiddleOfNight: "middle",
+	OneSeventh:    "seventh",
+	AngleBased:    "angle",
+}
+
+func (r HighLatitudeRule) String() string {
+	return highLatitudeRuleNames[r]
+}
+
+func (r HighLatitudeRule) MarshalText() ([]byte, error) {
+	return []byte(r.String()), nil
+}
+
+// ParseHighLatitudeRule returns the high latitude rule by name; `none`, `middle`, `seventh` or `angle`
+func ParseHighLatitudeRule(name string) (HighLatitudeRule, error) {
+	for rule, ruleName := range highLatitudeRuleNames {
+		if strings.EqualFold(name, ruleName) {
+			return rule, nil
+		}
+	}
+	return NoAdjustment, fmt.Errorf("undefined high latitude rule '%s'", name)
+}
+
+// MidnightMode is the method used to calculate (islamic) midnight
+type MidnightMode int
+
+const (
+	// StandardMidnight is the mid point from Sunset to Sunrise
+	StandardMidnight MidnightMode = iota
+	// JafariMidnight is the mid point from Sunset to Fajr
+	JafariMidnight
+)
+
+var midnightModeNames = map[MidnightMode]string{StandardMidnight: "standard", JafariMidnight: "jafari"}
+
+func (m MidnightMode) String() string {
+	return midnightModeNames[m]
+}
+
+func (m MidnightMode) MarshalText() ([]byte, error) {
+	return []byte(m.String()), nil
+}
+
+// ParseMidnightMode returns the midnight mode by name; `standard` or `jafari`
+func ParseMidnightMode(name string) (MidnightMode, error) {
+	for mode, modeName := range midnightModeNames {
+		if strings.EqualFold(name, modeName) {
+			return mode, nil
+		}
+	}
+	return StandardMidnight, fmt.Errorf("undefined midnight mode '%s'", name)
+}
+
+// Method holds the twilight parameters of a calculation method.
+// Isha and Maghrib are fixed intervals (in mins) after Maghrib and Sunset
+// respectively when their `Minutes` counterpart is set, otherwise they are sun angles.
+type Method struct {
+	ID             int          `json:"id"`
+	Name           string       `json:"name"`
+	Fajr           float64      `json:"fajr"`
+	Isha           float64      `json:"isha"`
+	IshaMinutes    float64      `json:"ishaMinutes,omitempty"`
+	Maghrib        float64      `json:"maghrib,omitempty"`
+	MaghribMinutes float64      `json:"maghribMinutes,omitempty"`
+	Midnight       MidnightMode `json:"midnight"`
+}
+
+// Calculation methods, identified by their Adhan API method ids
+// https://aladhan.com/calculation-methods
+var (
+	Jafari    = Method{ID: 0, Name: "Shia Ithna-Ashari, Leva Institute, Qum", Fajr: 16, Isha: 14, Maghrib: 4, Midnight: JafariMidnight}
+	Karachi   = Method{ID: 1, Name: "University of Islamic Sciences, Karachi", Fajr: 18, Isha: 18}
+	ISNA      = Method{ID: 2, Name: "Islamic Society of North America", Fajr: 15, Isha: 15}
+	MWL       = Method{ID: 3, Name: "Muslim World League", Fajr: 18, Isha: 17}
+	Makkah    = Method{ID: 4, Name: "Umm Al-Qura University, Makkah", Fajr: 18.5, IshaMinutes: 90}
+	Egypt     = Method{ID: 5, Name: "Egyptian General Authority of Survey", Fajr: 19.5, Isha: 17.5}
+	Tehran    = Method{ID: 7, Name: "Institute of Geophysics, University of Tehran", Fajr: 17.7, Isha: 14, Maghrib: 4.5, Midnight: JafariMidnight}
+	Gulf      = Method{ID: 8, Name: "Gulf Region", Fajr: 19.5, IshaMinutes: 90}
+	Kuwait    = Method{ID: 9, Name: "Kuwait", Fajr: 18, Isha: 17.5}
+	Qatar     = Method{ID: 10, Name: "Qatar", Fajr: 18, IshaMinutes: 90}
+	Singapore = Method{ID: 11, Name: "Majlis Ugama Islam Singapura, Singapore", Fajr: 20, Isha: 18}
+	France    = Method{ID: 12, Name: "Union Organization islamic de France", Fajr: 12, Isha: 12}
+	Turkey    = Method{ID: 13, Name: "Diyanet İşleri Başkanlığı, Turkey", Fajr: 18, Isha: 17}
+	Russia    = Method{ID: 14, Name: "Spiritual Administration of Muslims of Russia", Fajr: 16, Isha: 15}
+)
+
+// CustomMethodID is the Adhan API method id of custom calculation methods
+const CustomMethodID = 99
+
+// Custom returns a calculation method with custom Fajr and Isha angles
+func Custom(fajr, isha float64) Method {
+	return Method{ID: CustomMethodID, Name: "Custom", Fajr: fajr, Isha: isha}
+}
+
+// Methods holds the supported calculation methods by their short name
+var Methods = map[string]Method{
+	"Jafari":    Jafari,
+	"Karachi":   Karachi,
+	"ISNA":      ISNA,
+	"MWL":       MWL,
+	"Makkah":    Makkah,
+	"Egypt":     Egypt,
+	"Tehran":    Tehran,
+	"Gulf":      Gulf,
+	"Kuwait":    Kuwait,
+	"Qatar":     Qatar,
+	"Singapore": Singapore,
+	"France":    France,
+	"Turkey":    Turkey,
+	"Russia":    Russia,
+}
+
+// MethodByName returns the calculation method by its (case insensitive) short name
+func MethodByName(name string) (Method, error) {
+	for methodName, method := range Methods {
+		if strings.EqualFold(name, methodName) {
+			return method, nil
+		}
+	}
+	return Method{}, fmt.Errorf("undefined calculation method '%s'", name)
+}
+
+// MethodByID returns the calculation method with the Adhan API method id
+func MethodByID(id int) (Method, error) {
+	for _, method := range Methods {
+		if method.ID == id {
+			return method, nil
+		}
+	}
+	return Method{}, fmt.Errorf("undefined calculation method id '%d'", id)
+}
+
+// Params holds the parameters used to calculate prayer times
+type Params struct {
+	Method       Method           `json:"method"`
+	School       AsrSchool        `json:"school"`
+	HighLatitude HighLatitudeRule `json:"highLatitude"`
+	Midnight     MidnightMode     `json:"midnight"`
+}
+
+// DefaultParams returns the parameters of a calculation method with the default Asr school,
+// angle based high latitude adjustment and the midnight mode of the method
+func DefaultParams(method Method) Params {
+	return Params{
+		Method:       method,
+		School:       Standard,
+		HighLatitude: AngleBased,
+		Midnight:     method.Midnight,
+	}
+}
@@ -0,0 +1,210 @@
+// Package praytime calculates islamic prayer times offline from the position of the sun.
+// The calculations follow the PrayTimes.org algorithm (http://praytimes.org/calculation),
+// which is also the basis of the Adhan API (https://aladhan.com/calculation-methods).
+package praytime
+
+import (
+	"math"
+	"time"
+)
+
+// imsakMinutes is the interval (in mins) of Imsak prior to Fajr
+const imsakMinutes = 10
+
+// Coordinates is the geographical position for which prayer times are calculated
+type Coordinates struct {
+	Latitude  float64 `json:"latitude"`
+	Longitude float64 `json:"longitude"`
+	Elevation float64 `json:"elevation"` // in meters
+}
+
+// Times holds the calculated prayer times of a day.
+// Times which do not occur (i.e. sunrise near the poles) are zero.
+type Times struct {
+	Imsak    time.Time
+	Fajr     time.Time
+	Sunrise  time.Time
+	Dhuhr    time.Time
+	Asr      time.Time
+	Sunset   time.Time
+	Maghrib  time.Time
+	Isha     time.Time
+	Midnight time.Time
+}
+
+// hours holds prayer times as (fractional) hours of the day
+type hours struct {
+	imsak, fajr, sunrise, dhuhr, asr, sunset, maghrib, isha, midnight float64
+}
+
+type calculator struct {
+	coords Coordinates
+	params Params
+	jDate  float64
+}
+
+// Compute calculates the prayer times on the date (year, month, day) of `date` at the coordinates;
+// the resulting times are in the `location` timezone and rounded to the nearest minute
+func Compute(date time.Time, coords Coordinates, location *time.Location, params Params) Times {
+	year, month, day := date.Date()
+	c := calculator{
+		coords: coords,
+		params: params,
+		jDate:  julian(year, int(month), day) - coords.Longitude/(15*24),
+	}
+
+	// initial estimates of the times
+	t := hours{imsak: 5, fajr: 5, sunrise: 6, dhuhr: 12, asr: 13, sunset: 18, maghrib: 18, isha: 18}
+	t = c.computePrayerTimes(t)
+	t = c.adjustTimes(t)
+
+	// midnight is calculated from the adjusted times
+	if params.Midnight == JafariMidnight {
+		t.midnight = t.sunset + timeDiff(t.sunset, t.fajr)/2
+	} else {
+		t.midnight = t.sunset + timeDiff(t.sunset, t.sunrise)/2
+	}
+
+	midnightUTC := time.Date(year, month, day, 0, 0, 0, 0, time.UTC)
+	toTime := func(h float64) time.Time {
+		if math.IsNaN(h) || math.IsInf(h, 0) {
+			return time.Time{}
+		}
+		return midnightUTC.Add(time.Duration(math.Round(h*60)) * time.Minute).In(location)
+	}
+
+	return Times{
+		Imsak:    toTime(t.imsak),
+		Fajr:     toTime(t.fajr),
+		Sunrise:  toTime(t.sunrise),
+		Dhuhr:    toTime(t.dhuhr),
+		Asr:      toTime(t.asr),
+		Sunset:   toTime(t.sunset),
+		Maghrib:  toTime(t.maghrib),
+		Isha:     toTime(t.isha),
+		Midnight: toTime(t.midnight),
+	}
+}
+
+// computePrayerTimes computes the (local solar) prayer times from the estimated times
+func (c calculator) computePrayerTimes(t hours) hours {
+	method := c.params.Method
+	riseSet := c.riseSetAngle()
+
+	computed := hours{
+		fajr:    c.sunAngleTime(method.Fajr, t.fajr/24, true),
+		sunrise: c.sunAngleTime(riseSet, t.sunrise/24, true),
+		dhuhr:   c.midDay(t.dhuhr / 24),
+		asr:     c.asrTime(c.asrFactor(), t.asr/24),
+		sunset:  c.sunAngleTime(riseSet, t.sunset/24, false),
+	}
+	computed.maghrib = computed.sunset
+	if method.MaghribMinutes == 0 && method.Maghrib > 0 {
+		computed.maghrib = c.sunAngleTime(method.Maghrib, t.maghrib/24, false)
+	}
+	if method.IshaMinutes == 0 {
+		computed.isha = c.sunAngleTime(method.Isha, t.isha/24, false)
+	}
+	return computed
+}
+
+// adjustTimes converts the local solar times to UTC hours and applies the high latitude and minute based adjustments
+func (c calculator) adjustTimes(t hours) hours {
+	method := c.params.Method
+	offset := -c.coords.Longitude / 15
+
+	t.fajr += offset
+	t.sunrise += offset
+	t.dhuhr += offset
+	t.asr += offset
+	t.sunset += offset
+	t.maghrib += offset
+	t.isha += offset
+
+	if c.params.HighLatitude != NoAdjustment {
+		nightTime := timeDiff(t.sunset, t.sunrise)
+		t.fajr = c.adjustHighLatitudeTime(t.fajr, t.sunrise, method.Fajr, nightTime, true)
+		if method.IshaMinutes == 0 {
+			t.isha = c.adjustHighLatitudeTime(t.isha, t.sunset, method.Isha, nightTime, false)
+		}
+		if method.MaghribMinutes == 0 && method.Maghrib > 0 {
+			t.maghrib = c.adjustHighLatitudeTime(t.maghrib, t.sunset, method.Maghrib, nightTime, false)
+		}
+	}
+
+	t.imsak = t.fajr - imsakMinutes/60.0
+	if method.MaghribMinutes > 0 {
+		t.maghrib = t.sunset + method.MaghribMinutes/60
+	}
+	if method.IshaMinutes > 0 {
+		t.isha = t.maghrib + method.IshaMinutes/60
+	}
+	return t
+}
+
+// adjustHighLatitudeTime limits a twilight time to a portion of the night from its base (sunrise or sunset)
+func (c calculator) adjustHighLatitudeTime(t, base, angle, night float64, ccw bool) float64 {
+	portion := c.nightPortion(angle, night)
+	var diff float64
+	if ccw {
+		diff = timeDiff(t, base)
+	} else {
+		diff = timeDiff(base, t)
+	}
+	if math.IsNaN(t) || diff > portion {
+		if ccw {
+			return base - portion
+		}
+		return base + portion
+	}
+	return t
+}
+
+// nightPortion returns the portion of the night used by the high latitude rule
+func (c calculator) nightPortion(angle, night float64) float64 {
+	portion := 1.0 / 2
+	switch c.params.HighLatitude {
+	case AngleBased:
+		portion = angle / 60
+	case OneSeventh:
+		portion = 1.0 / 7
+	}
+	return portion * night
+}
+
+// midDay returns the time of solar noon
+func (c calculator) midDay(t float64) float64 {
+	_, equation := sunPosition(c.jDate + t)
+	return fixHour(12 - equation)
+}
+
+// sunAngleTime returns the time at which the sun reaches an angle below the horizon;
+// counter-clockwise (ccw) times are prior to noon
+func (c calculator) sunAngleTime(angle, t float64, ccw bool) float64 {
+	declination, _ := sunPosition(c.jDate + t)
+	noon := c.midDay(t)
+	lat := c.coords.Latitude
+	diff := darccos((-dsin(angle)-dsin(declination)*dsin(lat))/(dcos(declination)*dcos(lat))) / 15
+	if ccw {
+		return noon - diff
+	}
+	return noon + diff
+}
+
+// asrTime returns the time at which the shadow of an object is `factor` times its length
+func (c calculator) asrTime(factor, t float64) float64 {
+	declination, _ := sunPosition(c.jDate + t)
+	angle := -darccot(factor + dtan(math.Abs(c.coords.Latitude-declination)))
+	return c.sunAngleTime(angle, t, false)
+}
+
+func (c calculator) asrFactor() float64 {
+	if c.params.School == Hanafi {
+		return 2
+	}
+	return 1
+}
+
+func (c calculator) riseSetAngle() float64 {
+	return 0.833 + 0.0347*math.Sqrt(c.coords.Elevation)
+}
@@ -0,0 +1,221 @@
+package praytime
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"testing"
+	"time"
+)
+
+// fixture is the subset of an Adhan API calendar response used to verify calculations
+type fixture struct {
+	Data []struct {
+		Timings map[string]string `json:"timings"`
+		Date    struct {
+			Readable string `json:"readable"`
+		} `json:"date"`
+		Meta struct {
+			Latitude  float64 `json:"latitude"`
+			Longitude float64 `json:"longitude"`
+			Timezone  string  `json:"timezone"`
+			Method    struct {
+				ID int `json:"id"`
+			} `json:"method"`
+		} `json:"meta"`
+	} `json:"data"`
+}
+
+func loadFixture(t *testing.T, name string) fixture {
+	t.Helper()
+	f, err := os.Open("testdata/" + name)
+	if err != nil {
+		t.Fatalf("unable to open fixture: %s", err)
+	}
+	defer f.Close()
+
+	var fx fixture
+	if err := json.NewDecoder(f).Decode(&fx); err != nil {
+		t.Fatalf("unable to decode fixture: %s", err)
+	}
+	return fx
+}
+
+// TestComputeAgainstAladhan verifies calculated times against stored Adhan API calendars
+func TestComputeAgainstAladhan(t *testing.T) {
+	for _, name := range []string{"auckland-2021-01.json", "london-2021-06.json", "makkah-2021-06.json"} {
+		t.Run(fmt.Sprintf("test calculated times match %s", name), func(t *testing.T) {
+			fx := loadFixture(t, name)
+			for _, day := range fx.Data {
+				location, err := time.LoadLocation(day.Meta.Timezone)
+				if err != nil {
+					t.Fatalf("unexpected error: %s", err)
+				}
+				method, err := MethodByID(day.Meta.Method.ID)
+				if err != nil {
+					t.Fatalf("unexpected error: %s", err)
+				}
+				date, err := time.ParseInLocation("02 Jan 2006", day.Date.Readable, location)
+				if err != nil {
+					t.Fatalf("unexpected error: %s", err)
+				}
+
+				coords := Coordinates{Latitude: day.Meta.Latitude, Longitude: day.Meta.Longitude}
+				got := Compute(date, coords, location, DefaultParams(method))
+
+				for name, gotTime := range map[string]time.Time{
+					"Imsak":   got.Imsak,
+					"Fajr":    got.Fajr,
+					"Sunrise": got.Sunrise,
+					"Dhuhr":   got.Dhuhr,
+					"Asr":     got.Asr,
+					"Sunset":  got.Sunset,
+					"Maghrib": got.Maghrib,
+					"Isha":    got.Isha,
+				} {
+					want, err := time.ParseInLocation("02 Jan 2006 15:04 (MST)", fmt.Sprintf("%s %s", day.Date.Readable, day.Timings[name]), location)
+					if err != nil {
+						t.Fatalf("unexpected error: %s", err)
+					}
+					if diff := gotTime.Sub(want); diff > time.Minute || diff < -time.Minute {
+						t.Errorf("%s %s: want %s, got %s", day.Date.Readable, name, want, gotTime)
+					}
+				}
+			}
+		})
+	}
+}
+
+func TestCompute(t *testing.T) {
+	auckland, _ := time.LoadLocation("Pacific/Auckland")
+	coords := Coordinates{Latitude: -36.8484597, Longitude: 174.7633315}
+	date := time.Date(2021, 1, 1, 0, 0, 0, 0, auckland)
+
+	t.Run("test times are in chronological order", func(t *testing.T) {
+		got := Compute(date, coords, auckland, DefaultParams(MWL))
+		ordered := []time.Time{got.Imsak, got.Fajr, got.Sunrise, got.Dhuhr, got.Asr, got.Sunset, got.Isha, got.Midnight}
+		for i := 1; i < len(ordered); i++ {
+			if !ordered[i].After(ordered[i-1]) {
+				t.Errorf("want %s after %s", ordered[i], ordered[i-1])
+			}
+		}
+	})
+
+	t.Run("test hanafi asr is later than standard asr", func(t *testing.T) {
+		params := DefaultParams(MWL)
+		standard := Compute(date, coords, auckland, params)
+		params.School = Hanafi
+		hanafi := Compute(date, coords, auckland, params)
+
+		if !hanafi.Asr.After(standard.Asr) {
+			t.Errorf("want hanafi asr %s after standard asr %s", hanafi.Asr, standard.Asr)
+		}
+	})
+
+	t.Run("test isha is fixed interval after maghrib for minute based methods", func(t *testing.T) {
+		got := Compute(date, coords, auckland, DefaultParams(Makkah))
+		if diff := got.Isha.Sub(got.Maghrib); diff != 90*time.Minute {
+			t.Errorf("want %s, got %s", 90*time.Minute, diff)
+		}
+	})
+
+	t.Run("test high latitude adjustment provides fajr and isha", func(t *testing.T) {
+		oslo, _ := time.LoadLocation("Europe/Oslo")
+		summer := time.Date(2021, 6, 21, 0, 0, 0, 0, oslo)
+		coords := Coordinates{Latitude: 59.9138688, Longitude: 10.7522454}
+
+		params := DefaultParams(MWL)
+		params.HighLatitude = NoAdjustment
+		if got := Compute(summer, coords, oslo, params); !got.Fajr.IsZero() {
+			t.Errorf("want no fajr without adjustment, got %s", got.Fajr)
+		}
+
+		for _, rule := range []HighLatitudeRule{MiddleOfNight, OneSeventh, AngleBased} {
+			params.HighLatitude = rule
+			got := Compute(summer, coords, oslo, params)
+			if got.Fajr.IsZero() || got.Isha.IsZero() {
+				t.Errorf("want fajr and isha for rule %d, got %s and %s", rule, got.Fajr, got.Isha)
+			}
+			if !got.Fajr.Before(got.Sunrise) {
+				t.Errorf("want fajr %s before sunrise %s for rule %d", got.Fajr, got.Sunrise, rule)
+			}
+		}
+	})
+}
+
+func TestMethodByID(t *testing.T) {
+	t.Run("test method ids match adhan api", func(t *testing.T) {
+		for name, method := range Methods {
+			got, err := MethodByID(method.ID)
+			if err != nil {
+				t.Fatalf("unexpected error: %s", err)
+			}
+			if got != method {
+				t.Errorf("%s: want %v, got %v", name, method, got)
+			}
+		}
+	})
+
+	t.Run("test undefined method id", func(t *testing.T) {
+		if _, err := MethodByID(99); err == nil {
+			t.Error("want error, got nil")
+		}
+	})
+}
+
+func TestParse(t *testing.T) {
+	t.Run("test parsing names is case insensitive", func(t *testing.T) {
+		if got, err := MethodByName("isna"); err != nil || got != ISNA {
+			t.Errorf("want %v, got %v (err=%v)", ISNA, got, err)
+		}
+		if got, err := ParseAsrSchool("Hanafi"); err != nil || got != Hanafi {
+			t.Errorf("want %s, got %s (err=%v)", Hanafi, got, err)
+		}
+		if got, err := ParseHighLatitudeRule("SEVENTH"); err != nil || got != OneSeventh {
+			t.Errorf("want %s, got %s (err=%v)", OneSeventh, got, err)
+		}
+		if got, err := ParseMidnightMode("jafari"); err != nil || got != JafariMidnight {
+			t.Errorf("want %s, got %s (err=%v)", JafariMidnight, got, err)
+		}
+	})
+
+	t.Run("test parsing undefined names", func(t *testing.T) {
+		if _, err := MethodByName("unknown"); err == nil {
+			t.Error("want error for method, got nil")
+		}
+		if _, err := ParseAsrSchool("unknown"); err == nil {
+			t.Error("want error for school, got nil")
+		}
+		if _, err := ParseHighLatitudeRule("unknown"); err == nil {
+			t.Error("want error for high latitude rule, got nil")
+		}
+		if _, err := ParseMidnightMode("unknown"); err == nil {
+			t.Error("want error for midnight mode, got nil")
+		}
+	})
+}
+
+func TestToHijri(t *testing.T) {
+	t.Run("test gregorian dates convert to hijri dates", func(t *testing.T) {
+		for date, want := range map[time.Time]HijriDate{
+			time.Date(2021, 1, 1, 0, 0, 0, 0, time.UTC):   {Day: 17, Month: HijriMonths[4], Year: 1442},
+			time.Date(2021, 4, 14, 0, 0, 0, 0, time.UTC):  {Day: 2, Month: HijriMonths[8], Year: 1442},
+			time.Date(2020, 8, 20, 0, 0, 0, 0, time.UTC):  {Day: 1, Month: HijriMonths[0], Year: 1442},
+			time.Date(2024, 12, 31, 0, 0, 0, 0, time.UTC): {Day: 29, Month: HijriMonths[5], Year: 1446},
+		} {
+			if got := ToHijri(date, 0); got != want {
+				t.Errorf("%s: want %+v, got %+v", date.Format("2006-01-02"), want, got)
+			}
+		}
+	})
+
+	t.Run("test adjustment shifts hijri date", func(t *testing.T) {
+		date := time.Date(2021, 1, 1, 0, 0, 0, 0, time.UTC)
+		if got := ToHijri(date, -1); got.Day != 16 {
+			t.Errorf("want %d, got %d", 16, got.Day)
+		}
+		if got := ToHijri(date, 1); got.Day != 18 {
+			t.Errorf("want %d, got %d", 18, got.Day)
+		}
+	})
+}